@@ -0,0 +1,253 @@
+package tetraterm
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+	"github.com/solarlune/tetra3d"
+)
+
+type action struct {
+	name        string
+	description string
+	fn          func(selected tetra3d.INode) error
+}
+
+// actionList holds the actions registered through Server.RegisterAction().
+type actionList struct {
+	mutex   sync.Mutex
+	actions []action
+}
+
+func (actions *actionList) info() []actionInfo {
+
+	actions.mutex.Lock()
+	defer actions.mutex.Unlock()
+
+	out := make([]actionInfo, 0, len(actions.actions))
+	for _, a := range actions.actions {
+		out = append(out, actionInfo{Name: a.name, Description: a.description})
+	}
+
+	return out
+
+}
+
+func (actions *actionList) find(name string) (action, bool) {
+
+	actions.mutex.Lock()
+	defer actions.mutex.Unlock()
+
+	for _, a := range actions.actions {
+		if a.name == name {
+			return a, true
+		}
+	}
+
+	return action{}, false
+
+}
+
+// RegisterAction registers a named action that can be run from the terminal's Actions pane, like "kill enemy",
+// "give all items" or "trigger cutscene". The function is called on the game thread during Server.Update() with
+// the currently selected node; the error it returns (or its success) is displayed in the terminal. Registering
+// an action with the name of an existing action replaces it.
+//
+// Example usage:
+//
+//	server.RegisterAction("kill enemy", "Kills the selected enemy.", func(selected tetra3d.INode) error {
+//		enemy, ok := selected.Data().(*Enemy)
+//		if !ok {
+//			return errors.New("the selected node isn't an enemy")
+//		}
+//		enemy.Health = 0
+//		return nil
+//	})
+func (server *Server) RegisterAction(name, description string, fn func(selected tetra3d.INode) error) {
+
+	server.actions.mutex.Lock()
+	defer server.actions.mutex.Unlock()
+
+	a := action{name: name, description: description, fn: fn}
+
+	for i, existing := range server.actions.actions {
+		if existing.name == name {
+			server.actions.actions[i] = a
+			return
+		}
+	}
+
+	server.actions.actions = append(server.actions.actions, a)
+
+}
+
+// runAction runs the named action on the game thread against the selected node.
+func (server *Server) runAction(name string) error {
+
+	a, exists := server.actions.find(name)
+	if !exists {
+		return errors.New("no action named " + strconv.Quote(name))
+	}
+
+	return server.runOnGameThreadAndWait(func() (err error) {
+
+		defer func() {
+			if r := recover(); r != nil {
+				err = fmt.Errorf("panic: %v", r)
+			}
+		}()
+
+		return a.fn(server.selectedNode)
+
+	})
+
+}
+
+// initActionPane creates the Actions tool pane, which lists the actions registered through Server.RegisterAction()
+// and allows running them on the selected node.
+func (display *Display) initActionPane() {
+
+	filter := tview.NewInputField()
+	filter.SetLabel("Search Actions: ")
+	filter.SetLabelColor(tcell.ColorLightBlue)
+	filter.SetFieldBackgroundColor(tcell.ColorDarkSlateGray)
+	filter.SetFieldTextColor(tcell.ColorLightBlue)
+	filter.SetBackgroundColor(tcell.ColorDefault)
+
+	list := tview.NewList()
+	list.SetBackgroundColor(tcell.ColorDefault)
+	list.SetSecondaryTextColor(tcell.ColorGray)
+	list.SetMainTextColor(tcell.ColorWhite)
+
+	status := tview.NewTextView()
+	status.SetDynamicColors(true)
+	status.SetBackgroundColor(tcell.ColorDefault)
+	status.SetText("Enter: Run Action on Selected Node")
+
+	pane := tview.NewFlex()
+	pane.SetDirection(tview.FlexRow)
+	pane.SetBorder(true)
+	pane.SetTitle("[ Actions ]")
+	pane.AddItem(filter, 1, 0, true)
+	pane.AddItem(list, 0, 1, false)
+	pane.AddItem(status, 1, 0, false)
+
+	display.addToolPane("actions", tcell.KeyF5, pane)
+
+	actions := []actionInfo{}
+
+	run := func(name string) {
+
+		status.SetText("Running " + tview.Escape(name) + "...")
+
+		go func() {
+
+			text := "[green]" + tview.Escape(name) + " succeeded."
+
+			res, err := display.sendRequest(newActionRunPacket(name))
+			if err != nil {
+				text = "[red]" + tview.Escape(name) + " failed: " + tview.Escape(err.Error())
+			} else if errText := res.(*actionRunPacket).Error; errText != "" {
+				text = "[red]" + tview.Escape(name) + " failed: " + tview.Escape(errText)
+			}
+
+			display.App.QueueUpdate(func() {
+				status.SetText(text)
+			})
+
+		}()
+
+	}
+
+	refilter := func() {
+
+		current := ""
+		if list.GetItemCount() > 0 {
+			current, _ = list.GetItemText(list.GetCurrentItem())
+		}
+
+		list.Clear()
+
+		search := strings.ToLower(filter.GetText())
+
+		for _, a := range actions {
+			if search == "" || strings.Contains(strings.ToLower(a.Name), search) || strings.Contains(strings.ToLower(a.Description), search) {
+				name := a.Name
+				list.AddItem(tview.Escape(a.Name), tview.Escape(a.Description), 0, func() { run(name) })
+				if tview.Escape(a.Name) == current {
+					list.SetCurrentItem(list.GetItemCount() - 1)
+				}
+			}
+		}
+
+	}
+
+	filter.SetChangedFunc(func(text string) {
+		refilter()
+	})
+
+	filter.SetDoneFunc(func(key tcell.Key) {
+		if key == tcell.KeyEnter || key == tcell.KeyTab || key == tcell.KeyDown {
+			display.App.SetFocus(list)
+		}
+	})
+
+	list.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		// Typing in the list goes back to searching.
+		if event.Key() == tcell.KeyRune || event.Key() == tcell.KeyBacktab || (event.Key() == tcell.KeyUp && list.GetCurrentItem() == 0) {
+			if event.Key() == tcell.KeyRune {
+				filter.SetText(filter.GetText() + string(event.Rune()))
+			}
+			display.App.SetFocus(filter)
+			return nil
+		}
+		return event
+	})
+
+	go func() {
+
+		layout := ""
+
+		for {
+
+			time.Sleep(time.Millisecond * 500)
+
+			if !display.running.Load() {
+				return
+			}
+
+			if !display.toolPaneVisible("actions") {
+				continue
+			}
+
+			resp, err := display.sendRequest(newActionsPacket())
+			if err != nil {
+				continue
+			}
+
+			newActions := resp.(*actionsPacket).Actions
+
+			newLayout := ""
+			for _, a := range newActions {
+				newLayout += a.Name + "\x00" + a.Description + "\n"
+			}
+
+			if newLayout != layout {
+				layout = newLayout
+				display.App.QueueUpdate(func() {
+					actions = newActions
+					refilter()
+				})
+			}
+
+		}
+
+	}()
+
+}
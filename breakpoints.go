@@ -0,0 +1,588 @@
+package tetraterm
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/rivo/tview"
+	"github.com/solarlune/tetra3d"
+)
+
+// The maximum number of hits the Server holds onto until the terminal's notified of them.
+const breakpointHitBufferSize = 100
+
+const (
+	breakpointAdd = iota
+	breakpointRemove
+	breakpointToggle
+)
+
+// breakpointCondition evaluates a breakpoint's condition on the game thread, returning whether it's met, along
+// with a description of the values involved and the node it concerns, if any.
+type breakpointCondition func(server *Server) (met bool, detail string, node tetra3d.INode)
+
+type breakpoint struct {
+	id        int
+	condition string
+	evaluate  breakpointCondition
+	enabled   bool
+	wasMet    bool
+	hits      int
+	lastHit   string
+}
+
+type breakpointInfo struct {
+	ID        int
+	Condition string
+	Enabled   bool
+	Hits      int
+	LastHit   string
+}
+
+type breakpointHit struct {
+	ID        int
+	Condition string
+	Detail    string
+	NodeID    uint32
+	HasNode   bool
+}
+
+// breakpointList holds the breakpoints set from the terminal, along with the hits the terminal hasn't been
+// notified of yet.
+type breakpointList struct {
+	mutex       sync.Mutex
+	breakpoints []*breakpoint
+	nextID      int
+	hits        []breakpointHit
+	lastUpdate  time.Time
+	frameTime   time.Duration
+}
+
+var nodePropertyCondition = regexp.MustCompile(`(?i)^(.+)\.(position|worldposition|scale)\.(x|y|z)$`)
+var nodeVisibleCondition = regexp.MustCompile(`(?i)^(.+)\.visible$`)
+var frameTimeCondition = regexp.MustCompile(`(?i)^frame time `)
+
+// parseBreakpointCondition parses a breakpoint condition. Conditions can be in any of the following forms:
+//
+//	<node>.<position|worldposition|scale>.<x|y|z> <op> <number>   (i.e. Player.position.y < -50)
+//	<node>.visible <==|!=> <true|false>                            (i.e. /Root/Door.visible == false)
+//	<node> deleted                                                 (i.e. Boss deleted)
+//	<frametime|frame time> <op> <duration>                         (i.e. frame time > 30ms)
+//	<fps|tps> <op> <number>                                        (i.e. fps < 30)
+//
+// where <op> is one of <, <=, >, >=, ==, or !=. Nodes are given as in console commands. This should be called
+// on the game thread, as nodes to watch for deletion are resolved immediately.
+func (server *Server) parseBreakpointCondition(condition string) (breakpointCondition, error) {
+
+	normalized := strings.Join(strings.Fields(condition), " ")
+	normalized = frameTimeCondition.ReplaceAllString(normalized, "frametime ")
+
+	tokens, err := splitCommandLine(normalized)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(tokens) == 2 && strings.EqualFold(tokens[1], "deleted") {
+
+		if server.activeScene == nil {
+			return nil, errors.New("no scene set for server")
+		}
+
+		node, err := resolveNodePath(server.activeScene.Root, tokens[0])
+		if err != nil {
+			return nil, err
+		}
+
+		return func(server *Server) (bool, string, tetra3d.INode) {
+			if server.activeScene == nil || node.IsDescendantOf(server.activeScene.Root) {
+				return false, "", nil
+			}
+			// The node's been deleted, so we select its last parent instead, if it still exists.
+			parent := node.Parent()
+			if parent != nil && !parent.IsDescendantOf(server.activeScene.Root) && parent != server.activeScene.Root {
+				parent = nil
+			}
+			return true, node.Name() + " was removed from the scene", parent
+		}, nil
+
+	}
+
+	if len(tokens) != 3 {
+		return nil, errors.New("conditions should be in the form <value> <op> <value>, or <node> deleted")
+	}
+
+	lhs, op, rhs := tokens[0], tokens[1], tokens[2]
+
+	compare, err := comparison(op)
+	if err != nil {
+		return nil, err
+	}
+
+	switch strings.ToLower(lhs) {
+
+	case "frametime":
+
+		limit, err := time.ParseDuration(rhs)
+		if err != nil {
+			// Plain numbers are treated as milliseconds.
+			ms, numErr := strconv.ParseFloat(rhs, 64)
+			if numErr != nil {
+				return nil, err
+			}
+			limit = time.Duration(ms * float64(time.Millisecond))
+		}
+
+		return func(server *Server) (bool, string, tetra3d.INode) {
+			frameTime := server.breakpoints.frameTime
+			return compare(float64(frameTime), float64(limit)), "frame time = " + frameTime.Round(time.Microsecond).String(), nil
+		}, nil
+
+	case "fps", "tps":
+
+		limit, err := strconv.ParseFloat(rhs, 64)
+		if err != nil {
+			return nil, err
+		}
+
+		tps := strings.EqualFold(lhs, "tps")
+
+		return func(server *Server) (bool, string, tetra3d.INode) {
+			value := ebiten.ActualFPS()
+			if tps {
+				value = ebiten.ActualTPS()
+			}
+			return compare(value, limit), fmt.Sprintf("%s = %.2f", strings.ToUpper(lhs), value), nil
+		}, nil
+
+	}
+
+	if match := nodePropertyCondition.FindStringSubmatch(lhs); match != nil {
+
+		path, property, component := match[1], strings.ToLower(match[2]), strings.ToLower(match[3])
+
+		limit, err := strconv.ParseFloat(rhs, 64)
+		if err != nil {
+			return nil, err
+		}
+
+		return func(server *Server) (bool, string, tetra3d.INode) {
+
+			if server.activeScene == nil {
+				return false, "", nil
+			}
+
+			node, err := resolveNodePath(server.activeScene.Root, path)
+			if err != nil {
+				return false, "", nil
+			}
+
+			var vector tetra3d.Vector3
+			switch property {
+			case "position":
+				vector = node.LocalPosition()
+			case "worldposition":
+				vector = node.WorldPosition()
+			case "scale":
+				vector = node.LocalScale()
+			}
+
+			value := vector.X
+			switch component {
+			case "y":
+				value = vector.Y
+			case "z":
+				value = vector.Z
+			}
+
+			return compare(float64(value), limit), fmt.Sprintf("%s = %.4f", lhs, value), node
+
+		}, nil
+
+	}
+
+	if match := nodeVisibleCondition.FindStringSubmatch(lhs); match != nil {
+
+		path := match[1]
+
+		if op != "==" && op != "!=" {
+			return nil, errors.New("visibility can only be compared with == or !=")
+		}
+
+		visible, err := strconv.ParseBool(rhs)
+		if err != nil {
+			return nil, err
+		}
+
+		return func(server *Server) (bool, string, tetra3d.INode) {
+
+			if server.activeScene == nil {
+				return false, "", nil
+			}
+
+			node, err := resolveNodePath(server.activeScene.Root, path)
+			if err != nil {
+				return false, "", nil
+			}
+
+			met := node.IsVisible() == visible
+			if op == "!=" {
+				met = !met
+			}
+
+			return met, fmt.Sprintf("%s = %t", lhs, node.IsVisible()), node
+
+		}, nil
+
+	}
+
+	return nil, errors.New("unknown value " + strconv.Quote(lhs) + "; values are <node>.position.x (or y, z), <node>.worldposition.x, <node>.scale.x, <node>.visible, frametime, fps, and tps")
+
+}
+
+// comparison returns a function that compares two values using the operator given.
+func comparison(op string) (func(a, b float64) bool, error) {
+
+	switch op {
+	case "<":
+		return func(a, b float64) bool { return a < b }, nil
+	case "<=":
+		return func(a, b float64) bool { return a <= b }, nil
+	case ">":
+		return func(a, b float64) bool { return a > b }, nil
+	case ">=":
+		return func(a, b float64) bool { return a >= b }, nil
+	case "==":
+		return func(a, b float64) bool { return a == b }, nil
+	case "!=":
+		return func(a, b float64) bool { return a != b }, nil
+	}
+
+	return nil, errors.New("unknown operator " + strconv.Quote(op) + "; operators are <, <=, >, >=, ==, and !=")
+
+}
+
+// editBreakpoint adds, removes, or toggles a breakpoint.
+func (server *Server) editBreakpoint(packet *breakpointEditPacket) error {
+
+	switch packet.Action {
+
+	case breakpointAdd:
+
+		return server.runOnGameThreadAndWait(func() error {
+
+			evaluate, err := server.parseBreakpointCondition(packet.Condition)
+			if err != nil {
+				return err
+			}
+
+			server.breakpoints.mutex.Lock()
+			defer server.breakpoints.mutex.Unlock()
+
+			server.breakpoints.nextID++
+
+			server.breakpoints.breakpoints = append(server.breakpoints.breakpoints, &breakpoint{
+				id:        server.breakpoints.nextID,
+				condition: packet.Condition,
+				evaluate:  evaluate,
+				enabled:   true,
+			})
+
+			return nil
+
+		})
+
+	case breakpointRemove, breakpointToggle:
+
+		server.breakpoints.mutex.Lock()
+		defer server.breakpoints.mutex.Unlock()
+
+		for i, b := range server.breakpoints.breakpoints {
+			if b.id == packet.ID {
+				if packet.Action == breakpointRemove {
+					server.breakpoints.breakpoints = append(server.breakpoints.breakpoints[:i], server.breakpoints.breakpoints[i+1:]...)
+				} else {
+					b.enabled = !b.enabled
+					b.wasMet = false
+				}
+				return nil
+			}
+		}
+
+		return errors.New("no breakpoint with ID " + strconv.Itoa(packet.ID))
+
+	}
+
+	return nil
+
+}
+
+// update evaluates the breakpoints; this is called on the game thread from Server.Update(). Breakpoints are
+// hit when their conditions become met, pausing the game and selecting the node involved.
+func (breakpoints *breakpointList) update(server *Server) {
+
+	now := time.Now()
+	if !breakpoints.lastUpdate.IsZero() {
+		breakpoints.frameTime = now.Sub(breakpoints.lastUpdate)
+	}
+	breakpoints.lastUpdate = now
+
+	breakpoints.mutex.Lock()
+	defer breakpoints.mutex.Unlock()
+
+	for _, b := range breakpoints.breakpoints {
+
+		if !b.enabled {
+			continue
+		}
+
+		met, detail, node := false, "", tetra3d.INode(nil)
+
+		func() {
+			// Conditions that panic (i.e. from a node being in an unexpected state) are treated as not met.
+			defer func() { recover() }()
+			met, detail, node = b.evaluate(server)
+		}()
+
+		// Breakpoints are only hit when their conditions go from unmet to met, so they don't fire every frame.
+		if met && !b.wasMet {
+
+			b.hits++
+			b.lastHit = detail
+
+			hit := breakpointHit{
+				ID:        b.id,
+				Condition: b.condition,
+				Detail:    detail,
+			}
+
+			if node != nil {
+				server.selectedNode = node
+				hit.NodeID = node.ID()
+				hit.HasNode = true
+			}
+
+			breakpoints.hits = append(breakpoints.hits, hit)
+
+			// If the terminal isn't connected, only the most recent hits are kept.
+			if len(breakpoints.hits) > breakpointHitBufferSize {
+				breakpoints.hits = append(breakpoints.hits[:0], breakpoints.hits[len(breakpoints.hits)-breakpointHitBufferSize:]...)
+			}
+
+			server.SetPaused(true)
+
+		}
+
+		b.wasMet = met
+
+	}
+
+}
+
+// info returns the breakpoints, along with the hits the terminal hasn't been notified of yet.
+func (breakpoints *breakpointList) info() ([]breakpointInfo, []breakpointHit) {
+
+	breakpoints.mutex.Lock()
+	defer breakpoints.mutex.Unlock()
+
+	out := make([]breakpointInfo, 0, len(breakpoints.breakpoints))
+	for _, b := range breakpoints.breakpoints {
+		out = append(out, breakpointInfo{
+			ID:        b.id,
+			Condition: b.condition,
+			Enabled:   b.enabled,
+			Hits:      b.hits,
+			LastHit:   b.lastHit,
+		})
+	}
+
+	hits := breakpoints.hits
+	breakpoints.hits = nil
+
+	return out, hits
+
+}
+
+// initBreakpointPane creates the Breakpoints tool pane, which allows setting conditions that pause the game
+// when they're met.
+func (display *Display) initBreakpointPane() {
+
+	list := tview.NewList()
+	list.SetBackgroundColor(tcell.ColorDefault)
+	list.SetSecondaryTextColor(tcell.ColorGray)
+	list.SetMainTextColor(tcell.ColorWhite)
+
+	status := tview.NewTextView()
+	status.SetDynamicColors(true)
+	status.SetWrap(true)
+	status.SetBackgroundColor(tcell.ColorDefault)
+
+	pane := tview.NewFlex()
+	pane.SetDirection(tview.FlexRow)
+	pane.SetBorder(true)
+	pane.SetTitle("[ Breakpoints ]")
+	pane.AddItem(list, 0, 1, true)
+	pane.AddItem(status, 2, 0, false)
+
+	display.addToolPane("breakpoints", tcell.KeyF10, pane)
+
+	help := "[gray]A: Add, Enter: Enable / Disable, X: Remove, P: Resume Game[-]"
+	status.SetText(help)
+
+	breakpoints := []breakpointInfo{}
+
+	edit := func(action, id int, condition string) error {
+
+		packet := newBreakpointEditPacket(action)
+		packet.ID = id
+		packet.Condition = condition
+
+		res, err := display.sendRequest(packet)
+		if err != nil {
+			return err
+		}
+
+		if errText := res.(*breakpointEditPacket).Error; errText != "" {
+			return errors.New(errText)
+		}
+
+		return nil
+
+	}
+
+	refresh := func() {
+
+		current := list.GetCurrentItem()
+
+		list.Clear()
+
+		for _, b := range breakpoints {
+
+			text := "[green]●[-] " + tview.Escape(b.Condition)
+			if !b.Enabled {
+				text = "[gray]○ " + tview.Escape(b.Condition) + "[-]"
+			}
+
+			secondary := "Not hit yet"
+			if b.Hits > 0 {
+				secondary = fmt.Sprintf("Hit %d time(s); last: %s", b.Hits, b.LastHit)
+			}
+
+			list.AddItem(text, tview.Escape(secondary), 0, nil)
+
+		}
+
+		if current < list.GetItemCount() {
+			list.SetCurrentItem(current)
+		}
+
+	}
+
+	selected := func() (breakpointInfo, bool) {
+		index := list.GetCurrentItem()
+		if index < 0 || index >= len(breakpoints) {
+			return breakpointInfo{}, false
+		}
+		return breakpoints[index], true
+	}
+
+	list.SetSelectedFunc(func(index int, mainText, secondaryText string, shortcut rune) {
+		if b, ok := selected(); ok {
+			go edit(breakpointToggle, b.ID, "")
+		}
+	})
+
+	list.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+
+		switch event.Rune() {
+
+		case 'a', 'A':
+			display.promptValue("Add Breakpoint (i.e. Player.position.y < -50, Boss deleted, frame time > 30ms)", "", func(text string) error {
+				return edit(breakpointAdd, 0, text)
+			})
+			return nil
+
+		case 'x', 'X':
+			if b, ok := selected(); ok {
+				go edit(breakpointRemove, b.ID, "")
+			}
+			return nil
+
+		case 'p', 'P':
+			display.sendTimeControl(timeControlTogglePause, 0, 0)
+			return nil
+
+		}
+
+		return event
+
+	})
+
+	// Breakpoints are polled even when the pane's hidden, so hits can be shown as they happen.
+	go func() {
+
+		layout := ""
+
+		for {
+
+			time.Sleep(time.Millisecond * 250)
+
+			if !display.running.Load() {
+				return
+			}
+
+			res, err := display.sendRequest(newBreakpointsPacket())
+			if err != nil {
+				continue
+			}
+
+			packet := res.(*breakpointsPacket)
+
+			newLayout := fmt.Sprint(packet.Breakpoints)
+
+			if newLayout == layout && len(packet.Hits) == 0 {
+				continue
+			}
+
+			layout = newLayout
+
+			display.App.QueueUpdate(func() {
+
+				breakpoints = packet.Breakpoints
+				refresh()
+
+				if len(packet.Hits) == 0 {
+					return
+				}
+
+				hit := packet.Hits[len(packet.Hits)-1]
+
+				status.SetText("[red::b]Breakpoint hit:[-::-] " + tview.Escape(hit.Condition) + " [gray](" + tview.Escape(hit.Detail) + "); the game is paused.[-]\n" + help)
+
+				if hit.HasNode {
+					display.showTreeNode(hit.NodeID, sceneTreeAncestors(display.currentSceneTree, hit.NodeID))
+				}
+
+				if !display.toolPaneVisible("breakpoints") {
+					display.toggleToolPane("breakpoints")
+				}
+
+				for i, b := range breakpoints {
+					if b.ID == hit.ID {
+						list.SetCurrentItem(i)
+					}
+				}
+
+			})
+
+		}
+
+	}()
+
+}
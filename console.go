@@ -0,0 +1,720 @@
+package tetraterm
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+	"github.com/solarlune/tetra3d"
+)
+
+// ArgType represents the type of an argument to a console command.
+type ArgType int
+
+const (
+	ArgString ArgType = iota // A string argument; use quotes for strings containing spaces
+	ArgInt                   // An integer argument
+	ArgFloat                 // A floating-point argument
+	ArgBool                  // A boolean argument (true / false)
+	ArgNode                  // A node in the current scene, given as a path (like /Root/Player) or a node name
+)
+
+func (argType ArgType) String() string {
+	switch argType {
+	case ArgInt:
+		return "int"
+	case ArgFloat:
+		return "float"
+	case ArgBool:
+		return "bool"
+	case ArgNode:
+		return "node"
+	}
+	return "string"
+}
+
+// ArgSpec specifies an argument to a console command.
+type ArgSpec struct {
+	Name     string
+	Type     ArgType
+	Optional bool // Optional arguments can be left out; they should come after all required arguments
+}
+
+// CommandArgs holds the parsed arguments given to a console command, in the order of the command's ArgSpecs.
+// Optional arguments that weren't given are nil.
+type CommandArgs []any
+
+// String returns the argument at the given index as a string, or an empty string if it wasn't given.
+func (args CommandArgs) String(index int) string {
+	if index < len(args) {
+		if value, ok := args[index].(string); ok {
+			return value
+		}
+	}
+	return ""
+}
+
+// Int returns the argument at the given index as an int, or 0 if it wasn't given.
+func (args CommandArgs) Int(index int) int {
+	if index < len(args) {
+		if value, ok := args[index].(int); ok {
+			return value
+		}
+	}
+	return 0
+}
+
+// Float returns the argument at the given index as a float64, or 0 if it wasn't given.
+func (args CommandArgs) Float(index int) float64 {
+	if index < len(args) {
+		if value, ok := args[index].(float64); ok {
+			return value
+		}
+	}
+	return 0
+}
+
+// Bool returns the argument at the given index as a bool, or false if it wasn't given.
+func (args CommandArgs) Bool(index int) bool {
+	if index < len(args) {
+		if value, ok := args[index].(bool); ok {
+			return value
+		}
+	}
+	return false
+}
+
+// Node returns the argument at the given index as a node, or nil if it wasn't given.
+func (args CommandArgs) Node(index int) tetra3d.INode {
+	if index < len(args) {
+		if value, ok := args[index].(tetra3d.INode); ok {
+			return value
+		}
+	}
+	return nil
+}
+
+// Given returns if the argument at the given index was given.
+func (args CommandArgs) Given(index int) bool {
+	return index < len(args) && args[index] != nil
+}
+
+// CommandHandler handles a console command, returning text to print to the console, or an error.
+type CommandHandler func(args CommandArgs) (string, error)
+
+type command struct {
+	name    string
+	args    []ArgSpec
+	handler CommandHandler
+}
+
+func (c command) usage() string {
+	usage := c.name
+	for _, arg := range c.args {
+		if arg.Optional {
+			usage += " [" + arg.Name + ":" + arg.Type.String() + "]"
+		} else {
+			usage += " <" + arg.Name + ":" + arg.Type.String() + ">"
+		}
+	}
+	return usage
+}
+
+// commandList holds the console commands registered through Server.RegisterCommand().
+type commandList struct {
+	mutex    sync.Mutex
+	commands []command
+}
+
+func (commands *commandList) find(name string) (command, bool) {
+
+	commands.mutex.Lock()
+	defer commands.mutex.Unlock()
+
+	for _, c := range commands.commands {
+		if c.name == name {
+			return c, true
+		}
+	}
+
+	return command{}, false
+
+}
+
+func (commands *commandList) info() []commandInfo {
+
+	commands.mutex.Lock()
+	defer commands.mutex.Unlock()
+
+	out := make([]commandInfo, 0, len(commands.commands))
+	for _, c := range commands.commands {
+		out = append(out, commandInfo{Name: c.name, Usage: c.usage(), Args: c.args})
+	}
+
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+
+	return out
+
+}
+
+// RegisterCommand registers a command that can be run from the terminal's Console pane. The command's arguments
+// are parsed according to the ArgSpecs given before being passed to the handler, which is called on the game
+// thread during Server.Update(). The text returned by the handler is printed to the console, as is its error, if
+// it returns one. Registering a command with the name of an existing command replaces it. Commands can't use the
+// names of the terminal's built-in commands (like select or help); trying to register one logs a warning and
+// leaves it unregistered.
+//
+// Example usage:
+//
+//	server.RegisterCommand("spawn", []tetraterm.ArgSpec{
+//		{Name: "enemy", Type: tetraterm.ArgString},
+//		{Name: "parent", Type: tetraterm.ArgNode, Optional: true},
+//	}, func(args tetraterm.CommandArgs) (string, error) {
+//		enemy := game.Spawn(args.String(0), args.Node(1))
+//		return "spawned " + enemy.Name(), nil
+//	})
+func (server *Server) RegisterCommand(name string, args []ArgSpec, handler CommandHandler) {
+
+	// Built-in commands are run by the terminal before the game's, so a game command with the same name could
+	// never be run.
+	if _, builtIn := findSceneCommand(name); builtIn {
+		log.Printf("warning: can't register console command %q, as it's built into the terminal", name)
+		return
+	}
+
+	server.commands.mutex.Lock()
+	defer server.commands.mutex.Unlock()
+
+	c := command{name: name, args: args, handler: handler}
+
+	for i, existing := range server.commands.commands {
+		if existing.name == name {
+			server.commands.commands[i] = c
+			return
+		}
+	}
+
+	server.commands.commands = append(server.commands.commands, c)
+
+}
+
+// runCommand parses the command line given and runs the command on the game thread, returning its output.
+func (server *Server) runCommand(line string) (string, error) {
+
+	tokens, err := splitCommandLine(line)
+	if err != nil {
+		return "", err
+	}
+
+	if len(tokens) == 0 {
+		return "", nil
+	}
+
+	c, exists := server.commands.find(tokens[0])
+	if !exists {
+		return "", errors.New("unknown command " + strconv.Quote(tokens[0]) + "; type help for a list of commands")
+	}
+
+	var output string
+
+	err = server.runOnGameThreadAndWait(func() (err error) {
+
+		defer func() {
+			if r := recover(); r != nil {
+				err = fmt.Errorf("panic: %v", r)
+			}
+		}()
+
+		args, err := server.parseCommandArgs(c, tokens[1:])
+		if err != nil {
+			return err
+		}
+
+		output, err = c.handler(args)
+		return err
+
+	})
+
+	return output, err
+
+}
+
+// parseCommandArgs parses the arguments given according to the command's ArgSpecs. Node arguments are
+// resolved in the active scene, so this should be called on the game thread.
+func (server *Server) parseCommandArgs(c command, tokens []string) (CommandArgs, error) {
+
+	if len(tokens) > len(c.args) {
+		return nil, errors.New("too many arguments; usage: " + c.usage())
+	}
+
+	args := make(CommandArgs, len(c.args))
+
+	for i, spec := range c.args {
+
+		if i >= len(tokens) {
+			if !spec.Optional {
+				return nil, errors.New("missing argument " + spec.Name + "; usage: " + c.usage())
+			}
+			continue
+		}
+
+		token := tokens[i]
+
+		var err error
+
+		switch spec.Type {
+		case ArgString:
+			args[i] = token
+		case ArgInt:
+			args[i], err = strconv.Atoi(token)
+		case ArgFloat:
+			args[i], err = strconv.ParseFloat(token, 64)
+		case ArgBool:
+			args[i], err = strconv.ParseBool(token)
+		case ArgNode:
+			if server.activeScene == nil {
+				return nil, errors.New("no scene set for server")
+			}
+			args[i], err = resolveNodePath(server.activeScene.Root, token)
+		}
+
+		if err != nil {
+			return nil, errors.New("invalid " + spec.Type.String() + " argument " + spec.Name + ": " + err.Error())
+		}
+
+	}
+
+	return args, nil
+
+}
+
+// resolveNodePath returns the node found at the path given, starting from the root node. Paths are made
+// up of node names separated by slashes, starting with the root's name (i.e. /Root/Player). A path without
+// any slashes is treated as the name of a node to search for anywhere in the tree.
+func resolveNodePath(root tetra3d.INode, path string) (tetra3d.INode, error) {
+
+	if !strings.Contains(path, "/") {
+		if root.Name() == path {
+			return root, nil
+		}
+		for _, node := range root.SearchTree().INodes() {
+			if node.Name() == path {
+				return node, nil
+			}
+		}
+		return nil, errors.New("no node named " + strconv.Quote(path))
+	}
+
+	names := strings.Split(strings.Trim(path, "/"), "/")
+
+	if names[0] != root.Name() {
+		return nil, errors.New("paths should start with the root node, /" + root.Name())
+	}
+
+	node := root
+
+	for _, name := range names[1:] {
+
+		var found tetra3d.INode
+		for _, child := range node.Children() {
+			if child.Name() == name {
+				found = child
+				break
+			}
+		}
+
+		if found == nil {
+			return nil, errors.New("no node at " + strconv.Quote(path))
+		}
+
+		node = found
+
+	}
+
+	return node, nil
+
+}
+
+// splitCommandLine splits a command line into tokens, separated by whitespace; double quotes can be used to
+// include whitespace in a token.
+func splitCommandLine(line string) ([]string, error) {
+
+	tokens := []string{}
+	current := strings.Builder{}
+	inQuotes := false
+	inToken := false
+
+	for _, r := range line {
+
+		switch {
+
+		case r == '"':
+			inQuotes = !inQuotes
+			inToken = true
+
+		case (r == ' ' || r == '\t') && !inQuotes:
+			if inToken {
+				tokens = append(tokens, current.String())
+				current.Reset()
+				inToken = false
+			}
+
+		default:
+			current.WriteRune(r)
+			inToken = true
+
+		}
+
+	}
+
+	if inQuotes {
+		return nil, errors.New("unterminated quotes")
+	}
+
+	if inToken {
+		tokens = append(tokens, current.String())
+	}
+
+	return tokens, nil
+
+}
+
+// quoteCommandToken quotes a token if it contains whitespace, so it can be used in a command line.
+func quoteCommandToken(token string) string {
+	if strings.ContainsAny(token, " \t") {
+		return `"` + token + `"`
+	}
+	return token
+}
+
+// nodePaths returns the paths of all nodes in the scene tree, as used for node arguments to console commands.
+func nodePaths(tree sceneNode) []string {
+
+	paths := []string{}
+
+	var loop func(node sceneNode, parentPath string)
+
+	loop = func(node sceneNode, parentPath string) {
+		path := parentPath + "/" + node.Name
+		paths = append(paths, path)
+		for _, child := range node.Children {
+			loop(child, path)
+		}
+	}
+
+	loop(tree, "")
+
+	return paths
+
+}
+
+// initConsolePane creates the Console tool pane, which allows running the built-in scene commands, as well as
+// commands registered through Server.RegisterCommand().
+func (display *Display) initConsolePane() {
+
+	output := tview.NewTextView()
+	output.SetDynamicColors(true)
+	output.SetScrollable(true)
+	output.SetMaxLines(1000)
+	output.SetBackgroundColor(tcell.ColorDefault)
+	output.SetText("Type help for a list of commands.\n")
+
+	input := tview.NewInputField()
+	input.SetLabel("> ")
+	input.SetLabelColor(tcell.ColorLightBlue)
+	input.SetFieldBackgroundColor(tcell.ColorDarkSlateGray)
+	input.SetFieldTextColor(tcell.ColorLightBlue)
+	input.SetBackgroundColor(tcell.ColorDefault)
+
+	pane := tview.NewFlex()
+	pane.SetDirection(tview.FlexRow)
+	pane.SetBorder(true)
+	pane.SetTitle("[ Console ]")
+	pane.AddItem(output, 0, 1, false)
+	pane.AddItem(input, 1, 0, true)
+
+	display.addToolPane("console", tcell.KeyF6, pane)
+	display.consoleOutput = output
+
+	history := []string{}
+	historyIndex := 0
+	commands := []commandInfo{}
+
+	refreshCommands := func() {
+		commands = sceneCommandInfo()
+		if res, err := display.sendRequest(newCommandsPacket()); err == nil {
+			commands = append(commands, res.(*commandsPacket).Commands...)
+		}
+	}
+
+	// Lines are run one at a time in the order they're entered, so pasting several lines at once runs them
+	// like a script.
+	display.consoleLines = make(chan string, 256)
+
+	go func() {
+		for line := range display.consoleLines {
+			display.runConsoleLine(line)
+		}
+	}()
+
+	input.SetDoneFunc(func(key tcell.Key) {
+
+		if key != tcell.KeyEnter {
+			return
+		}
+
+		line := strings.TrimSpace(input.GetText())
+		input.SetText("")
+
+		if line == "" {
+			return
+		}
+
+		if len(history) == 0 || history[len(history)-1] != line {
+			history = append(history, line)
+		}
+		historyIndex = len(history)
+
+		select {
+		case display.consoleLines <- line:
+		default:
+			display.printToConsole("[red]Too many commands are waiting to run; " + tview.Escape(line) + " was skipped.[-]")
+		}
+
+	})
+
+	input.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+
+		switch event.Key() {
+
+		case tcell.KeyUp:
+			if historyIndex > 0 {
+				historyIndex--
+				input.SetText(history[historyIndex])
+			}
+			return nil
+
+		case tcell.KeyDown:
+			if historyIndex < len(history)-1 {
+				historyIndex++
+				input.SetText(history[historyIndex])
+			} else {
+				historyIndex = len(history)
+				input.SetText("")
+			}
+			return nil
+
+		case tcell.KeyPgUp, tcell.KeyPgDn:
+			row, _ := output.GetScrollOffset()
+			_, _, _, height := output.GetInnerRect()
+			if event.Key() == tcell.KeyPgUp {
+				row -= height
+			} else {
+				row += height
+			}
+			if row < 0 {
+				row = 0
+			}
+			output.ScrollTo(row, 0)
+			return nil
+
+		case tcell.KeyTab:
+			refreshCommands()
+			completed, candidates := completeCommandLine(input.GetText(), commands, nodePaths(display.currentSceneTree))
+			input.SetText(completed)
+			if len(candidates) > 1 {
+				display.printToConsole("[gray]" + tview.Escape(strings.Join(candidates, "  ")) + "[-]")
+			}
+			return nil
+
+		}
+
+		return event
+
+	})
+
+}
+
+// printToConsole prints a line of text (which can contain color tags) to the Console pane.
+func (display *Display) printToConsole(text string) {
+	fmt.Fprintln(display.consoleOutput, text)
+	display.consoleOutput.ScrollToEnd()
+}
+
+// runConsoleLine runs a single line entered into the console, printing the line and its output. Built-in scene
+// commands are run by the Display, while other commands are sent to the game. This blocks until the command is
+// done, so it shouldn't be called from the UI goroutine. It returns whether the command succeeded.
+func (display *Display) runConsoleLine(line string) bool {
+
+	display.App.QueueUpdate(func() {
+		display.printToConsole("[lightblue]> " + tview.Escape(line) + "[-]")
+	})
+
+	output := ""
+	errText := ""
+
+	tokens, err := splitCommandLine(line)
+
+	if err != nil {
+		errText = err.Error()
+	} else if len(tokens) == 0 {
+		return true
+	} else if c, builtIn := findSceneCommand(tokens[0]); builtIn {
+
+		output, err = c.run(display, tokens[1:])
+		if err != nil {
+			errText = err.Error()
+		}
+
+	} else {
+
+		res, err := display.sendRequest(newCommandRunPacket(line))
+		if err != nil {
+			errText = err.Error()
+		} else {
+			output = res.(*commandRunPacket).Output
+			errText = res.(*commandRunPacket).Error
+		}
+
+	}
+
+	lines := []string{}
+
+	if output != "" {
+		lines = append(lines, tview.Escape(output))
+	}
+
+	if errText != "" {
+		lines = append(lines, "[red]"+tview.Escape(errText)+"[-]")
+	}
+
+	if len(lines) > 0 {
+		display.App.QueueUpdate(func() {
+			display.printToConsole(strings.Join(lines, "\n"))
+		})
+	}
+
+	return errText == ""
+
+}
+
+// consoleHelp returns the usage of the built-in scene commands and the game's commands.
+func (display *Display) consoleHelp() string {
+
+	lines := []string{"Scene Commands:"}
+	for _, c := range sceneCommands() {
+		lines = append(lines, "  "+c.usage)
+	}
+
+	lines = append(lines, "", "Game Commands:")
+
+	res, err := display.sendRequest(newCommandsPacket())
+	if err != nil {
+		lines = append(lines, "  (couldn't fetch the game's commands: "+err.Error()+")")
+	} else if commands := res.(*commandsPacket).Commands; len(commands) == 0 {
+		lines = append(lines, "  (none registered through Server.RegisterCommand())")
+	} else {
+		for _, c := range commands {
+			lines = append(lines, "  "+c.Usage)
+		}
+	}
+
+	lines = append(lines, "", "Nodes can be given as /Root/Path/To/Node, or as a partial path like Player or Enemies/*.")
+
+	return strings.Join(lines, "\n")
+
+}
+
+// sceneCommandInfo returns information about the built-in scene commands, for completion.
+func sceneCommandInfo() []commandInfo {
+	out := []commandInfo{}
+	for _, c := range sceneCommands() {
+		out = append(out, commandInfo{Name: c.name, Usage: c.usage, Args: c.args})
+	}
+	return out
+}
+
+// completeCommandLine completes the last token of the command line given, using the names of commands for
+// the first token and node paths for node arguments. It returns the completed line, along with all of the
+// candidates that matched.
+func completeCommandLine(line string, commands []commandInfo, paths []string) (string, []string) {
+
+	tokens, err := splitCommandLine(line)
+	if err != nil {
+		return line, nil
+	}
+
+	// If the line ends in whitespace, we're completing a new, empty token.
+	if len(tokens) == 0 || strings.HasSuffix(line, " ") {
+		tokens = append(tokens, "")
+	}
+
+	last := tokens[len(tokens)-1]
+	candidates := []string{}
+
+	if len(tokens) == 1 {
+
+		for _, c := range commands {
+			if strings.HasPrefix(c.Name, last) {
+				candidates = append(candidates, c.Name)
+			}
+		}
+
+	} else {
+
+		argIndex := len(tokens) - 2
+
+		for _, c := range commands {
+			if c.Name == tokens[0] && argIndex < len(c.Args) && c.Args[argIndex].Type == ArgNode {
+				for _, path := range paths {
+					if strings.HasPrefix(strings.ToLower(path), strings.ToLower(last)) {
+						candidates = append(candidates, path)
+					}
+				}
+			}
+		}
+
+	}
+
+	if len(candidates) == 0 {
+		return line, nil
+	}
+
+	// The common prefix is found rune by rune, so node names with multi-byte characters aren't cut in half.
+	completion := []rune(candidates[0])
+	for _, candidate := range candidates[1:] {
+		c := []rune(candidate)
+		i := 0
+		for i < len(completion) && i < len(c) && unicode.ToLower(completion[i]) == unicode.ToLower(c[i]) {
+			i++
+		}
+		completion = completion[:i]
+	}
+
+	if len(completion) < utf8.RuneCountInString(last) {
+		return line, candidates
+	}
+
+	tokens[len(tokens)-1] = string(completion)
+
+	quoted := make([]string, 0, len(tokens))
+	for _, token := range tokens {
+		quoted = append(quoted, quoteCommandToken(token))
+	}
+
+	completed := strings.Join(quoted, " ")
+	if len(candidates) == 1 {
+		completed += " "
+	}
+
+	return completed, candidates
+
+}
@@ -0,0 +1,281 @@
+package tetraterm
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+	"github.com/solarlune/tetra3d"
+)
+
+// How long the terminal must have checked for crash reports within for Server.Recover() to wait for it to
+// receive one.
+const crashPollTimeout = time.Second
+
+// How long Server.Recover() waits for the terminal to receive and save a crash report before re-panicking.
+const crashReportTimeout = time.Second * 3
+
+// The number of recent log records included in crash reports.
+const crashLogCount = 20
+
+type crashReport struct {
+	Time       time.Time
+	Panic      string
+	Stack      string
+	SceneTree  sceneNode
+	HasNode    bool
+	NodePath   string
+	NodeID     uint32
+	NodeType   string
+	Position   tetra3d.Vector3
+	Scale      tetra3d.Vector3
+	Visible    bool
+	RecentLogs []logRecord
+}
+
+// crashState holds the crash report created by Server.Recover() until the terminal receives it.
+type crashState struct {
+	mutex    sync.Mutex
+	report   *crashReport
+	received chan struct{}
+	lastPoll time.Time
+}
+
+// Recover forwards panics to the terminal, which displays the panic along with its stack trace and the last
+// known state of the scene and selected node in its Crash pane, and saves the report to disk. Recover should
+// be deferred at the start of your game's Update() and Draw() functions; after the terminal saves the
+// report (or if it's not connected), the panic continues as usual.
+//
+// Example usage:
+//
+//	func (g *Game) Update() error {
+//		defer g.DebugServer.Recover()
+//		...
+//	}
+func (server *Server) Recover() {
+
+	r := recover()
+	if r == nil {
+		return
+	}
+
+	report := server.newCrashReport(r, string(debug.Stack()))
+
+	server.crash.mutex.Lock()
+	server.crash.report = report
+	received := make(chan struct{})
+	server.crash.received = received
+	connected := time.Since(server.crash.lastPoll) < crashPollTimeout
+	server.crash.mutex.Unlock()
+
+	// Only wait for the terminal if it's connected and checking for crashes.
+	if connected {
+		select {
+		case <-received:
+		case <-time.After(crashReportTimeout):
+		}
+	}
+
+	panic(r)
+
+}
+
+// newCrashReport creates a crash report of the panic value and stack trace given, along with the current state
+// of the scene and selected node. Anything that panics while creating the report is left out.
+func (server *Server) newCrashReport(panicValue any, stack string) *crashReport {
+
+	report := &crashReport{
+		Time:       time.Now(),
+		Panic:      fmt.Sprint(panicValue),
+		Stack:      stack,
+		RecentLogs: server.logs.recent(crashLogCount),
+	}
+
+	func() {
+
+		defer func() { recover() }()
+
+		if server.activeScene != nil {
+			report.SceneTree = constructNodeTree(server.activeScene.Root)
+		}
+
+	}()
+
+	func() {
+
+		defer func() { recover() }()
+
+		node := server.selectedNode
+		if node == nil {
+			return
+		}
+
+		report.NodeID = node.ID()
+		report.NodePath = node.Path()
+		report.NodeType = fmt.Sprint(node.Type())
+		report.Position = node.LocalPosition()
+		report.Scale = node.LocalScale()
+		report.Visible = node.IsVisible()
+		report.HasNode = true
+
+	}()
+
+	return report
+
+}
+
+// take returns the crash report that hasn't been sent to the terminal yet, if there is one. It also records
+// that the terminal is checking for crashes.
+func (crash *crashState) take() (crashReport, bool) {
+
+	crash.mutex.Lock()
+	defer crash.mutex.Unlock()
+
+	crash.lastPoll = time.Now()
+
+	if crash.report == nil {
+		return crashReport{}, false
+	}
+
+	report := *crash.report
+	crash.report = nil
+
+	return report, true
+
+}
+
+// acknowledge lets Server.Recover() continue panicking once the terminal has saved the crash report it took.
+func (crash *crashState) acknowledge() {
+
+	crash.mutex.Lock()
+	defer crash.mutex.Unlock()
+
+	if crash.received != nil {
+		close(crash.received)
+		crash.received = nil
+	}
+
+}
+
+// String returns the crash report as plain text, as saved to disk.
+func (report crashReport) String() string {
+
+	builder := strings.Builder{}
+
+	builder.WriteString("TetraTerm Crash Report\n")
+	builder.WriteString(report.Time.Format(time.RFC1123) + "\n\n")
+	builder.WriteString("panic: " + report.Panic + "\n\n")
+
+	if report.HasNode {
+		builder.WriteString(fmt.Sprintf("Selected Node: %s (ID: %d, Type: %s)\n", report.NodePath, report.NodeID, report.NodeType))
+		builder.WriteString(fmt.Sprintf("  Pos: %v\n  Sca: %v\n  Visible: %t\n", report.Position, report.Scale, report.Visible))
+	}
+
+	builder.WriteString("\n" + report.Stack + "\n")
+
+	if len(report.RecentLogs) > 0 {
+		builder.WriteString("Recent Log:\n")
+		for _, record := range report.RecentLogs {
+			line := record.Time.Format("15:04:05.000") + " " + record.Level.String() + " " + record.Message
+			if record.Attrs != "" {
+				line += " " + record.Attrs
+			}
+			builder.WriteString("  " + line + "\n")
+		}
+		builder.WriteString("\n")
+	}
+
+	if report.SceneTree.Name != "" {
+
+		builder.WriteString("Scene Tree:\n")
+
+		var loop func(node sceneNode, depth int)
+		loop = func(node sceneNode, depth int) {
+			builder.WriteString(strings.Repeat("  ", depth+1) + node.Name + "\n")
+			for _, child := range node.Children {
+				loop(child, depth+1)
+			}
+		}
+
+		loop(report.SceneTree, 0)
+
+	}
+
+	return builder.String()
+
+}
+
+// initCrashPane creates the Crash tool pane, which displays the last crash report received from the game.
+// Crash reports are saved to disk and shown automatically when received.
+func (display *Display) initCrashPane() {
+
+	view := tview.NewTextView()
+	view.SetDynamicColors(true)
+	view.SetScrollable(true)
+	view.SetBackgroundColor(tcell.ColorDefault)
+	view.SetText("No crashes have been reported. Defer Server.Recover() in your game's Update() and Draw() functions to report panics here.")
+
+	pane := tview.NewFlex()
+	pane.SetDirection(tview.FlexRow)
+	pane.SetBorder(true)
+	pane.SetTitle("[ Crash Report ]")
+	pane.AddItem(view, 0, 1, true)
+
+	display.addToolPane("crash", tcell.KeyF8, pane)
+
+	go func() {
+
+		for {
+
+			time.Sleep(time.Millisecond * 250)
+
+			if !display.running.Load() {
+				return
+			}
+
+			res, err := display.sendRequest(newCrashPacket())
+			if err != nil {
+				continue
+			}
+
+			packet := res.(*crashPacket)
+			if !packet.Crashed {
+				continue
+			}
+
+			report := packet.Report
+
+			header := "[red::b]The game crashed: " + tview.Escape(report.Panic) + "[-::-]\n"
+
+			path := filepath.Join(display.CrashDirectory, "tetraterm-crash-"+report.Time.Format("20060102-150405")+".txt")
+			if err := os.WriteFile(path, []byte(report.String()), 0644); err != nil {
+				header += "[yellow]Couldn't save the crash report: " + tview.Escape(err.Error()) + "[-]\n"
+			} else {
+				header += "[green]Saved to " + tview.Escape(path) + "[-]\n"
+			}
+
+			// The game waits for this before it continues panicking, so the report's saved even if the game exits.
+			display.sendRequest(newCrashAckPacket())
+
+			display.App.QueueUpdate(func() {
+
+				view.SetText(header + "\n" + tview.Escape(report.String()))
+				view.ScrollToBeginning()
+
+				if !display.toolPaneVisible("crash") {
+					display.toggleToolPane("crash")
+				}
+
+			})
+
+		}
+
+	}()
+
+}
@@ -0,0 +1,516 @@
+package tetraterm
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/vector"
+	"github.com/rivo/tview"
+	"github.com/solarlune/tetra3d"
+	"github.com/solarlune/tetra3d/colors"
+	"github.com/solarlune/tetra3d/math32"
+)
+
+// The category debug shapes are drawn in if none is given.
+const defaultDebugCategory = "default"
+
+// The number of segments used to draw each circle of debug spheres.
+const debugSphereSegments = 24
+
+const (
+	debugDrawQuery = iota
+	debugDrawToggleCategory
+	debugDrawShowAllCategories
+)
+
+// DebugDrawOptions controls how shapes drawn through the Server's immediate-mode debug drawing functions (like
+// Server.DebugLine()) look and how long they last.
+type DebugDrawOptions struct {
+	Color     tetra3d.Color // The color of the shape; if left empty, the shape is white.
+	Thickness float32       // The thickness of the shape's lines in pixels; if left at 0, it's 1 pixel.
+	// How many ticks (calls to Server.Update()) the shape lasts for; if left at 0, it lasts for one tick, which
+	// is the usual for shapes drawn every tick. Ticks don't pass while the game's paused from the terminal.
+	Frames int
+	// How long the shape lasts for in real time; this takes priority over Frames if set.
+	Duration time.Duration
+	// The category the shape belongs to, which can be shown or hidden from the terminal's Debug Overlay pane
+	// (F12); if left empty, it's "default".
+	Category string
+}
+
+type debugShapeKind int
+
+const (
+	debugShapeLine debugShapeKind = iota
+	debugShapeArrow
+	debugShapeSphere
+	debugShapeBox
+	debugShapeText
+)
+
+type debugShape struct {
+	kind      debugShapeKind
+	a, b      tetra3d.Vector3
+	radius    float32
+	text      string
+	color     tetra3d.Color
+	thickness float32
+	category  string
+	addedTick int
+	frames    int
+	expires   time.Time
+}
+
+type debugCategory struct {
+	hidden bool
+	shapes int
+}
+
+type debugCategoryInfo struct {
+	Name   string
+	Hidden bool
+	Shapes int
+}
+
+// debugShapes holds the shapes drawn through the Server's immediate-mode debug drawing functions.
+type debugShapes struct {
+	mutex      sync.Mutex
+	shapes     []debugShape
+	tick       int
+	categories map[string]*debugCategory
+}
+
+// add adds a shape to be drawn, filling out its properties from the options given.
+func (shapes *debugShapes) add(shape debugShape, options *DebugDrawOptions) {
+
+	if options == nil {
+		options = &DebugDrawOptions{}
+	}
+
+	shape.color = options.Color
+	if shape.color == (tetra3d.Color{}) {
+		shape.color = colors.White()
+	}
+
+	shape.thickness = options.Thickness
+	if shape.thickness <= 0 {
+		shape.thickness = 1
+	}
+
+	shape.category = options.Category
+	if shape.category == "" {
+		shape.category = defaultDebugCategory
+	}
+
+	shape.frames = options.Frames
+	if shape.frames <= 0 {
+		shape.frames = 1
+	}
+
+	if options.Duration > 0 {
+		shape.expires = time.Now().Add(options.Duration)
+	}
+
+	shapes.mutex.Lock()
+	defer shapes.mutex.Unlock()
+
+	if shapes.categories == nil {
+		shapes.categories = map[string]*debugCategory{}
+	}
+
+	category, exists := shapes.categories[shape.category]
+	if !exists {
+		category = &debugCategory{}
+		shapes.categories[shape.category] = category
+	}
+
+	// Shapes in hidden categories are dropped right away, so drawing lots of them costs little.
+	if category.hidden {
+		return
+	}
+
+	shape.addedTick = shapes.tick
+	shapes.shapes = append(shapes.shapes, shape)
+
+}
+
+// update expires shapes whose lifetimes have run out. This is called on the game thread from Server.Update();
+// tickRan should be false if the game skipped updating this tick because it's paused, so shapes drawn for a
+// number of ticks remain visible while paused.
+func (shapes *debugShapes) update(tickRan bool) {
+
+	shapes.mutex.Lock()
+	defer shapes.mutex.Unlock()
+
+	if tickRan {
+		shapes.tick++
+	}
+
+	now := time.Now()
+
+	remaining := shapes.shapes[:0]
+
+	for _, category := range shapes.categories {
+		category.shapes = 0
+	}
+
+	for _, shape := range shapes.shapes {
+
+		if !shape.expires.IsZero() {
+			if now.After(shape.expires) {
+				continue
+			}
+		} else if shapes.tick-shape.addedTick > shape.frames {
+			continue
+		}
+
+		if shapes.categories[shape.category].hidden {
+			continue
+		}
+
+		shapes.categories[shape.category].shapes++
+
+		remaining = append(remaining, shape)
+
+	}
+
+	shapes.shapes = remaining
+
+}
+
+// draw draws the shapes to the screen using the camera given.
+func (shapes *debugShapes) draw(screen *ebiten.Image, camera *tetra3d.Camera) {
+
+	shapes.mutex.Lock()
+	defer shapes.mutex.Unlock()
+
+	for _, shape := range shapes.shapes {
+		drawDebugShape(screen, camera, shape)
+	}
+
+}
+
+// drawDebugShape draws the debug shape given through the camera given.
+func drawDebugShape(screen *ebiten.Image, camera *tetra3d.Camera, shape debugShape) {
+
+	switch shape.kind {
+
+	case debugShapeLine:
+		drawDebugLine3D(screen, camera, shape.a, shape.b, shape.color, shape.thickness)
+
+	case debugShapeArrow:
+
+		drawDebugLine3D(screen, camera, shape.a, shape.b, shape.color, shape.thickness)
+
+		// The arrow's head is drawn in screen space, so it's always visible regardless of the arrow's angle.
+		if debugDepth(camera, shape.a) < camera.Near() || debugDepth(camera, shape.b) < camera.Near() {
+			return
+		}
+
+		from := camera.WorldToScreenPixels(shape.a)
+		to := camera.WorldToScreenPixels(shape.b)
+
+		angle := math32.Atan2(to.Y-from.Y, to.X-from.X)
+		headLength := 8 + shape.thickness*2
+
+		for _, side := range []float32{-1, 1} {
+			wingAngle := angle + math32.Pi + side*math32.Pi/6
+			vector.StrokeLine(screen, to.X, to.Y, to.X+math32.Cos(wingAngle)*headLength, to.Y+math32.Sin(wingAngle)*headLength, shape.thickness, shape.color.ToNRGBA64(), true)
+		}
+
+	case debugShapeSphere:
+
+		// Spheres are drawn as three circles, one around each axis.
+		for axis := 0; axis < 3; axis++ {
+
+			point := func(angle float32) tetra3d.Vector3 {
+				sin, cos := math32.Sin(angle)*shape.radius, math32.Cos(angle)*shape.radius
+				switch axis {
+				case 0:
+					return shape.a.Add(tetra3d.Vector3{X: cos, Y: sin})
+				case 1:
+					return shape.a.Add(tetra3d.Vector3{X: cos, Z: sin})
+				}
+				return shape.a.Add(tetra3d.Vector3{Y: cos, Z: sin})
+			}
+
+			for i := 0; i < debugSphereSegments; i++ {
+				start := float32(i) / debugSphereSegments * math32.Pi * 2
+				end := float32(i+1) / debugSphereSegments * math32.Pi * 2
+				drawDebugLine3D(screen, camera, point(start), point(end), shape.color, shape.thickness)
+			}
+
+		}
+
+	case debugShapeBox:
+
+		half := shape.b.Scale(0.5)
+		min := shape.a.Sub(half)
+		max := shape.a.Add(half)
+
+		corner := func(x, y, z bool) tetra3d.Vector3 {
+			c := min
+			if x {
+				c.X = max.X
+			}
+			if y {
+				c.Y = max.Y
+			}
+			if z {
+				c.Z = max.Z
+			}
+			return c
+		}
+
+		for _, b := range []bool{false, true} {
+			for _, c := range []bool{false, true} {
+				drawDebugLine3D(screen, camera, corner(false, b, c), corner(true, b, c), shape.color, shape.thickness)
+				drawDebugLine3D(screen, camera, corner(b, false, c), corner(b, true, c), shape.color, shape.thickness)
+				drawDebugLine3D(screen, camera, corner(b, c, false), corner(b, c, true), shape.color, shape.thickness)
+			}
+		}
+
+	case debugShapeText:
+
+		if debugDepth(camera, shape.a) < camera.Near() {
+			return
+		}
+
+		pos := camera.WorldToScreenPixels(shape.a)
+		camera.DrawDebugText(screen, shape.text, pos.X, pos.Y, 1, shape.color)
+
+	}
+
+}
+
+// categoryInfo returns the categories of debug shapes, sorted by name.
+func (shapes *debugShapes) categoryInfo() []debugCategoryInfo {
+
+	shapes.mutex.Lock()
+	defer shapes.mutex.Unlock()
+
+	out := []debugCategoryInfo{}
+
+	for name, category := range shapes.categories {
+		out = append(out, debugCategoryInfo{Name: name, Hidden: category.hidden, Shapes: category.shapes})
+	}
+
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+
+	return out
+
+}
+
+// handleDebugDraw applies the debug draw action given in the packet, filling it out with the resulting state.
+func (server *Server) handleDebugDraw(packet *debugDrawPacket) {
+
+	shapes := &server.debugShapes
+
+	shapes.mutex.Lock()
+
+	switch packet.Action {
+
+	case debugDrawToggleCategory:
+		if category, exists := shapes.categories[packet.Category]; exists {
+			category.hidden = !category.hidden
+		}
+
+	case debugDrawShowAllCategories:
+		for _, category := range shapes.categories {
+			category.hidden = false
+		}
+
+	}
+
+	shapes.mutex.Unlock()
+
+	packet.Categories = shapes.categoryInfo()
+
+}
+
+// debugDepth returns how far in front of the camera the point given is.
+func debugDepth(camera *tetra3d.Camera, point tetra3d.Vector3) float32 {
+	// Cameras look down their -Z axis.
+	return point.Sub(camera.WorldPosition()).Dot(camera.WorldRotation().Forward().Invert())
+}
+
+// drawDebugLine3D draws a line between the two points in the world given, clipping it against the camera's near
+// plane so lines passing behind the camera are drawn correctly.
+func drawDebugLine3D(screen *ebiten.Image, camera *tetra3d.Camera, a, b tetra3d.Vector3, color tetra3d.Color, thickness float32) {
+
+	near := camera.Near()
+
+	depthA := debugDepth(camera, a)
+	depthB := debugDepth(camera, b)
+
+	if depthA < near && depthB < near {
+		return
+	}
+
+	if depthA < near {
+		a = a.Add(b.Sub(a).Scale((near - depthA) / (depthB - depthA)))
+	} else if depthB < near {
+		b = b.Add(a.Sub(b).Scale((near - depthB) / (depthA - depthB)))
+	}
+
+	start := camera.WorldToScreenPixels(a)
+	end := camera.WorldToScreenPixels(b)
+
+	vector.StrokeLine(screen, start.X, start.Y, end.X, end.Y, thickness, color.ToNRGBA64(), true)
+
+}
+
+// DebugLine draws a line between the two points given, in world space. Like the Server's other immediate-mode
+// debug drawing functions, this can be called from anywhere in the game's Update() to visualize things like
+// raycasts, and the shape is drawn by Server.Draw() through the game's camera. If options is nil, the line
+// is white and lasts for one tick.
+func (server *Server) DebugLine(from, to tetra3d.Vector3, options *DebugDrawOptions) {
+	server.debugShapes.add(debugShape{kind: debugShapeLine, a: from, b: to}, options)
+}
+
+// DebugArrow draws an arrow pointing from one point to another, in world space.
+func (server *Server) DebugArrow(from, to tetra3d.Vector3, options *DebugDrawOptions) {
+	server.debugShapes.add(debugShape{kind: debugShapeArrow, a: from, b: to}, options)
+}
+
+// DebugSphere draws a wireframe sphere with the center and radius given, in world space.
+func (server *Server) DebugSphere(center tetra3d.Vector3, radius float32, options *DebugDrawOptions) {
+	server.debugShapes.add(debugShape{kind: debugShapeSphere, a: center, radius: radius}, options)
+}
+
+// DebugBox draws a wireframe axis-aligned box with the center and size (its full width, height, and depth)
+// given, in world space.
+func (server *Server) DebugBox(center, size tetra3d.Vector3, options *DebugDrawOptions) {
+	server.debugShapes.add(debugShape{kind: debugShapeBox, a: center, b: size}, options)
+}
+
+// DebugText3D draws text at the position given, in world space.
+func (server *Server) DebugText3D(position tetra3d.Vector3, text string, options *DebugDrawOptions) {
+	server.debugShapes.add(debugShape{kind: debugShapeText, a: position, text: text}, options)
+}
+
+// initDebugOverlayPane creates the Debug Overlay tool pane, which shows and hides categories of the shapes
+// drawn through the Server's immediate-mode debug drawing functions.
+func (display *Display) initDebugOverlayPane() {
+
+	categories := tview.NewList()
+	categories.SetBackgroundColor(tcell.ColorDefault)
+	categories.SetMainTextColor(tcell.ColorWhite)
+	categories.SetSecondaryTextColor(tcell.ColorGray)
+	categories.ShowSecondaryText(false)
+	categories.SetBorder(true)
+	categories.SetTitle("[ Debug Shape Categories ]")
+
+	status := tview.NewTextView()
+	status.SetDynamicColors(true)
+	status.SetBackgroundColor(tcell.ColorDefault)
+	status.SetText("[gray]Enter: Show / Hide Category, A: Show All, 4: Show / Hide All Debug Shapes, O: Overlay Options[-]")
+
+	pane := tview.NewFlex()
+	pane.SetDirection(tview.FlexRow)
+	pane.AddItem(categories, 0, 1, true)
+	pane.AddItem(status, 1, 0, false)
+
+	display.addToolPane("overlay", tcell.KeyF12, pane)
+
+	infos := []debugCategoryInfo{}
+
+	setCategories := func(newInfos []debugCategoryInfo) {
+
+		current := categories.GetCurrentItem()
+
+		infos = newInfos
+
+		categories.Clear()
+
+		if len(infos) == 0 {
+			categories.AddItem("[gray]No debug shapes drawn yet (see Server.DebugLine(), Server.DebugSphere(), etc.)[-]", "", 0, nil)
+		}
+
+		for _, info := range infos {
+			text := fmt.Sprintf("[green]■[-] %s [gray](%d shapes)[-]", tview.Escape(info.Name), info.Shapes)
+			if info.Hidden {
+				text = "[gray]□ " + tview.Escape(info.Name) + " (hidden)[-]"
+			}
+			categories.AddItem(text, "", 0, nil)
+		}
+
+		if current < categories.GetItemCount() {
+			categories.SetCurrentItem(current)
+		}
+
+	}
+
+	send := func(packet *debugDrawPacket) {
+		go func() {
+			res, err := display.sendRequest(packet)
+			if err != nil {
+				return
+			}
+			display.App.QueueUpdate(func() { setCategories(res.(*debugDrawPacket).Categories) })
+		}()
+	}
+
+	categories.SetSelectedFunc(func(index int, mainText, secondaryText string, shortcut rune) {
+		if index < len(infos) {
+			packet := newDebugDrawPacket(debugDrawToggleCategory)
+			packet.Category = infos[index].Name
+			send(packet)
+		}
+	})
+
+	categories.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		switch event.Rune() {
+		case 'a', 'A':
+			send(newDebugDrawPacket(debugDrawShowAllCategories))
+			return nil
+		case 'o', 'O':
+			display.showOverlayOptions()
+			return nil
+		case '4':
+			// The root hotkeys are ignored while a tool pane's focused, so this pane handles its own.
+			go display.sendRequest(newToggleDebugDrawShapes())
+			return nil
+		}
+		return event
+	})
+
+	setCategories(infos)
+
+	go func() {
+
+		layout := ""
+
+		for {
+
+			time.Sleep(time.Millisecond * 500)
+
+			if !display.running.Load() {
+				return
+			}
+
+			if !display.toolPaneVisible("overlay") {
+				continue
+			}
+
+			res, err := display.sendRequest(newDebugDrawPacket(debugDrawQuery))
+			if err != nil {
+				continue
+			}
+
+			newInfos := res.(*debugDrawPacket).Categories
+
+			if newLayout := fmt.Sprint(newInfos); newLayout != layout {
+				layout = newLayout
+				display.App.QueueUpdate(func() { setCategories(newInfos) })
+			}
+
+		}
+
+	}()
+
+}
@@ -5,7 +5,6 @@ import (
 	"errors"
 
 	"github.com/hajimehoshi/ebiten/v2"
-	"github.com/hajimehoshi/ebiten/v2/inpututil"
 	"github.com/solarlune/tetra3d"
 	"github.com/solarlune/tetra3d/colors"
 
@@ -23,6 +22,8 @@ type Game struct {
 	Camera  *tetra3d.Camera
 
 	DebugServer *tetraterm.Server
+
+	CubeRotationSpeed float64
 }
 
 func NewGame() *Game {
@@ -51,20 +52,42 @@ func (g *Game) Init() {
 	// and specify the starting scene.
 	g.DebugServer = tetraterm.NewServer(nil)
 
+	// Values can be registered as tweaks so they can be edited live from the terminal's Tweaks pane (F4).
+	g.CubeRotationSpeed = 0.01
+	g.DebugServer.Tweak("cube rotation speed", &g.CubeRotationSpeed, &tetraterm.TweakOptions{Min: 0, Max: 0.2, Step: 0.01})
+
 }
 
 func (g *Game) Update() error {
 
-	cube := g.Scene.Root.Get("Cube")
-	cube.Rotate(0, 1, 0, 0.01)
+	// Deferring Recover() forwards panics to the terminal's Crash Report pane (F8) before the game exits.
+	defer g.DebugServer.Recover()
+
+	// The game can be paused, stepped through tick by tick, and slowed down or sped up from the terminal.
+	if !g.DebugServer.Paused() || g.DebugServer.ShouldStep() {
+
+		// Code can be timed using named scopes, which are displayed in the terminal's Timing Scopes pane (F2).
+		g.DebugServer.Time("rotate cube", func() {
+			cube := g.Scene.Root.Get("Cube")
+			cube.Rotate(0, 1, 0, float32(g.CubeRotationSpeed*g.DebugServer.TimeScale()))
+		})
+
+		// Shapes can be drawn from gameplay code to visualize things; these can be hidden by category from the
+		// terminal's Debug Overlay pane (F12).
+		cube := g.Scene.Root.Get("Cube")
+		g.DebugServer.DebugArrow(cube.WorldPosition(), cube.WorldPosition().Add(cube.WorldRotation().Right().Scale(3)), &tetraterm.DebugDrawOptions{Color: colors.SkyBlue(), Category: "cube"})
+
+	}
 
 	var err error
 
-	if inpututil.IsKeyJustPressed(ebiten.KeyEscape) {
+	// Checking input through the server allows keys to be pressed from the terminal's Input pane (F11), even
+	// while the game window is unfocused.
+	if g.DebugServer.IsKeyJustPressed(ebiten.KeyEscape) {
 		err = errors.New("quit")
 	}
 
-	if inpututil.IsKeyJustPressed(ebiten.KeyF4) {
+	if g.DebugServer.IsKeyJustPressed(ebiten.KeyF4) {
 		ebiten.SetFullscreen(!ebiten.IsFullscreen())
 	}
 
@@ -0,0 +1,259 @@
+package tetraterm
+
+import (
+	"sync"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+	"github.com/hajimehoshi/ebiten/v2/vector"
+	"github.com/solarlune/tetra3d"
+	"github.com/solarlune/tetra3d/math32"
+)
+
+// The radius in pixels of the icons drawn at the center of each gizmo; Ctrl+clicking within twice this distance
+// of an icon selects its node.
+const gizmoIconRadius = 6
+
+// gizmoPick holds the node last selected by clicking on its gizmo in the game window, until the terminal is
+// told about it through the game info packet.
+type gizmoPick struct {
+	mutex   sync.Mutex
+	nodeID  uint32
+	hasNode bool
+}
+
+// take returns the ID of the node picked since the last call, if there was one.
+func (pick *gizmoPick) take() (uint32, bool) {
+	pick.mutex.Lock()
+	defer pick.mutex.Unlock()
+	nodeID, hasNode := pick.nodeID, pick.hasNode
+	pick.hasNode = false
+	return nodeID, hasNode
+}
+
+// hasGizmo returns if a gizmo is drawn for the node given when drawn through the camera given; that is, if the
+// node is a light, a path, or a camera other than the one rendering.
+func hasGizmo(node tetra3d.INode, camera *tetra3d.Camera) bool {
+	if node == camera {
+		return false
+	}
+	t := node.Type()
+	return t.Is(tetra3d.NodeTypeLight) || t.Is(tetra3d.NodeTypeCamera) || t.Is(tetra3d.NodeTypePath)
+}
+
+// updateGizmoPicking selects the node whose gizmo icon is closest to the cursor when the game window is
+// Ctrl+clicked, if gizmos are being drawn.
+func (server *Server) updateGizmoPicking() {
+
+	camera := server.t3dCamera
+
+	if !server.DebugDrawGizmos || camera == nil || !ebiten.IsKeyPressed(ebiten.KeyControl) || !inpututil.IsMouseButtonJustPressed(ebiten.MouseButtonLeft) {
+		return
+	}
+
+	cx, cy := ebiten.CursorPosition()
+	cursor := tetra3d.Vector3{X: float32(cx), Y: float32(cy)}
+
+	var picked tetra3d.INode
+	closest := float32(gizmoIconRadius * 2)
+
+	// Only the nodes whose gizmos are drawn can be picked.
+	root := server.overlayRoot()
+
+	for _, node := range append([]tetra3d.INode{root}, root.SearchTree().INodes()...) {
+
+		if !hasGizmo(node, camera) || debugDepth(camera, node.WorldPosition()) < camera.Near() {
+			continue
+		}
+
+		pos := camera.WorldToScreenPixels(node.WorldPosition())
+		pos.Z = 0
+
+		if distance := pos.DistanceTo(cursor); distance < closest {
+			closest = distance
+			picked = node
+		}
+
+	}
+
+	if picked != nil {
+		server.selectedNode = picked
+		server.gizmoPick.mutex.Lock()
+		server.gizmoPick.nodeID = picked.ID()
+		server.gizmoPick.hasNode = true
+		server.gizmoPick.mutex.Unlock()
+	}
+
+}
+
+// drawGizmos draws gizmos for the lights, paths, and cameras (other than the one given) in the tree starting
+// with the root given.
+func (server *Server) drawGizmos(screen *ebiten.Image, camera *tetra3d.Camera, root tetra3d.INode) {
+
+	overlay := server.DebugOverlay
+
+	for _, node := range append([]tetra3d.INode{root}, root.SearchTree().INodes()...) {
+
+		if !hasGizmo(node, camera) {
+			continue
+		}
+
+		color := overlay.LightGizmoColor
+
+		if node.Type().Is(tetra3d.NodeTypeCamera) {
+			color = overlay.CameraGizmoColor
+		} else if node.Type().Is(tetra3d.NodeTypePath) {
+			color = overlay.PathGizmoColor
+		}
+
+		// Gizmos for lights that are off are dimmed.
+		if light, ok := node.(tetra3d.ILight); ok && !light.IsOn() {
+			color.A *= 0.35
+		}
+
+		thickness := float32(1)
+		if node == server.selectedNode {
+			thickness = 2
+		}
+
+		pos := node.WorldPosition()
+		rotation := node.WorldRotation()
+
+		switch n := node.(type) {
+
+		case *tetra3d.PointLight:
+			if n.Range > 0 {
+				drawDebugShape(screen, camera, debugShape{kind: debugShapeSphere, a: pos, radius: n.Range, color: color, thickness: thickness})
+			}
+
+		case *tetra3d.DirectionalLight:
+			// Directional lights shine along their forward vector.
+			drawDebugShape(screen, camera, debugShape{kind: debugShapeArrow, a: pos, b: pos.Add(rotation.Forward().Scale(overlay.GizmoLength)), color: color, thickness: thickness})
+
+		case *tetra3d.CubeLight:
+			drawGizmoBox(screen, camera, node.Transform(), n.Dimensions, color, thickness)
+			direction := rotation.MultVec(n.LightingAngle).Unit()
+			drawDebugShape(screen, camera, debugShape{kind: debugShapeArrow, a: pos, b: pos.Add(direction.Scale(overlay.GizmoLength)), color: color, thickness: thickness})
+
+		case *tetra3d.Camera:
+			drawGizmoFrustum(screen, camera, n, overlay.GizmoLength, color, thickness)
+
+		case *tetra3d.Path:
+			points := n.Points()
+			for i := 0; i < len(points)-1; i++ {
+				drawDebugLine3D(screen, camera, points[i], points[i+1], color, thickness)
+			}
+			if n.Closed && len(points) > 2 {
+				drawDebugLine3D(screen, camera, points[len(points)-1], points[0], color, thickness)
+			}
+			for _, point := range points {
+				if debugDepth(camera, point) >= camera.Near() {
+					p := camera.WorldToScreenPixels(point)
+					vector.DrawFilledRect(screen, p.X-2, p.Y-2, 4, 4, color.ToNRGBA64(), false)
+				}
+			}
+
+		}
+
+		// The icon at the node's center is what's clicked to select it.
+		if debugDepth(camera, pos) >= camera.Near() {
+
+			p := camera.WorldToScreenPixels(pos)
+
+			if light, ok := node.(tetra3d.ILight); ok {
+				lightColor := light.Color()
+				lightColor.A = color.A
+				vector.DrawFilledCircle(screen, p.X, p.Y, gizmoIconRadius-2, lightColor.ToNRGBA64(), true)
+			}
+
+			vector.StrokeCircle(screen, p.X, p.Y, gizmoIconRadius, thickness, color.ToNRGBA64(), true)
+
+		}
+
+	}
+
+}
+
+// drawGizmoBox draws the edges of the dimensions given, transformed by the matrix given.
+func drawGizmoBox(screen *ebiten.Image, camera *tetra3d.Camera, transform tetra3d.Matrix4, dimensions tetra3d.Dimensions, color tetra3d.Color, thickness float32) {
+
+	corner := func(x, y, z bool) tetra3d.Vector3 {
+		c := dimensions.Min
+		if x {
+			c.X = dimensions.Max.X
+		}
+		if y {
+			c.Y = dimensions.Max.Y
+		}
+		if z {
+			c.Z = dimensions.Max.Z
+		}
+		return transform.MultVec(c)
+	}
+
+	for _, b := range []bool{false, true} {
+		for _, c := range []bool{false, true} {
+			drawDebugLine3D(screen, camera, corner(false, b, c), corner(true, b, c), color, thickness)
+			drawDebugLine3D(screen, camera, corner(b, false, c), corner(b, true, c), color, thickness)
+			drawDebugLine3D(screen, camera, corner(b, c, false), corner(b, c, true), color, thickness)
+		}
+	}
+
+}
+
+// drawGizmoFrustum draws the view frustum of the target camera through the camera given, from its near plane to
+// its far plane, or to the length given if that's closer.
+func drawGizmoFrustum(screen *ebiten.Image, camera, target *tetra3d.Camera, length float32, color tetra3d.Color, thickness float32) {
+
+	pos := target.WorldPosition()
+	rotation := target.WorldRotation()
+	right := rotation.Right()
+	up := rotation.Up()
+	forward := rotation.Forward().Invert() // Cameras look down their -Z axis.
+
+	far := target.Far()
+	if length > 0 && length < far {
+		far = length
+	}
+
+	// plane returns the corners of the frustum's cross-section at the depth given.
+	plane := func(depth float32) [4]tetra3d.Vector3 {
+
+		var halfWidth, halfHeight float32
+
+		if target.Perspective() {
+			halfHeight = depth * math32.Tan(math32.ToRadians(target.FieldOfView()/2))
+			halfWidth = halfHeight * target.AspectRatio()
+		} else {
+			// This matches the orthographic projection used by Camera.Projection().
+			w, h := target.Size()
+			halfWidth = target.OrthoScale()
+			halfHeight = halfWidth * float32(h) / float32(w)
+		}
+
+		center := pos.Add(forward.Scale(depth))
+
+		return [4]tetra3d.Vector3{
+			center.Add(right.Scale(-halfWidth)).Add(up.Scale(halfHeight)),
+			center.Add(right.Scale(halfWidth)).Add(up.Scale(halfHeight)),
+			center.Add(right.Scale(halfWidth)).Add(up.Scale(-halfHeight)),
+			center.Add(right.Scale(-halfWidth)).Add(up.Scale(-halfHeight)),
+		}
+
+	}
+
+	near := plane(target.Near())
+	end := plane(far)
+
+	for i := 0; i < 4; i++ {
+		next := (i + 1) % 4
+		drawDebugLine3D(screen, camera, near[i], near[next], color, thickness)
+		drawDebugLine3D(screen, camera, end[i], end[next], color, thickness)
+		drawDebugLine3D(screen, camera, near[i], end[i], color, thickness)
+	}
+
+	// A line from the camera's position to its near plane shows which way it's facing, even if the near plane's
+	// too small to see.
+	drawDebugLine3D(screen, camera, pos, pos.Add(forward.Scale(target.Near())), color, thickness)
+
+}
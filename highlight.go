@@ -0,0 +1,135 @@
+package tetraterm
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/vector"
+	"github.com/solarlune/tetra3d"
+	"github.com/solarlune/tetra3d/math32"
+)
+
+// How many times per second the selected node flashes when OverlaySettings.FlashSelected is true.
+const highlightFlashRate = 2
+
+// How far in pixels the arrow pointing to an off-screen selected node is drawn from the edges of the screen.
+const highlightArrowMargin = 24
+
+// selectionHighlight holds the image the selected node's outline is drawn to.
+type selectionHighlight struct {
+	outlineImage *ebiten.Image
+}
+
+// draw draws the flashing, outline, and off-screen arrow highlights for the selected node, if they're enabled.
+// The highlights are drawn over the rendered scene, rather than changing the selected models, so they don't
+// affect the game's state.
+func (highlight *selectionHighlight) draw(server *Server, screen *ebiten.Image, camera *tetra3d.Camera) {
+
+	overlay := server.DebugOverlay
+	selected := server.selectedNode
+
+	if selected == nil || selected == server.activeScene.Root || selected == camera {
+		return
+	}
+
+	if overlay.FlashSelected {
+		seconds := float32(time.Now().UnixMilli()%1000) / 1000
+		pulse := (math32.Sin(seconds*math32.Pi*2*highlightFlashRate) + 1) / 2
+		color := overlay.HighlightColor
+		color.A *= pulse * 0.75
+		camera.DrawDebugWireframe(screen, selected, color)
+	}
+
+	if overlay.OutlineSelected {
+
+		// The wireframe's drawn to a separate image that's then drawn offset in each direction, thickening
+		// its lines so it stands out from the wireframe overlay.
+		w, h := screen.Bounds().Dx(), screen.Bounds().Dy()
+
+		if highlight.outlineImage == nil || highlight.outlineImage.Bounds().Dx() != w || highlight.outlineImage.Bounds().Dy() != h {
+			if highlight.outlineImage != nil {
+				highlight.outlineImage.Deallocate()
+			}
+			highlight.outlineImage = ebiten.NewImage(w, h)
+		}
+
+		highlight.outlineImage.Clear()
+		camera.DrawDebugWireframe(highlight.outlineImage, selected, overlay.HighlightColor)
+
+		for _, offset := range [][2]float64{{-1, 0}, {1, 0}, {0, -1}, {0, 1}, {0, 0}} {
+			opt := &ebiten.DrawImageOptions{}
+			opt.GeoM.Translate(offset[0], offset[1])
+			screen.DrawImage(highlight.outlineImage, opt)
+		}
+
+	}
+
+	if overlay.PointToSelected {
+		drawOffScreenArrow(screen, camera, selected, overlay.HighlightColor)
+	}
+
+}
+
+// drawOffScreenArrow draws an arrow at the edge of the screen pointing toward the node given, along with its name
+// and distance, if the node's off-screen.
+func drawOffScreenArrow(screen *ebiten.Image, camera *tetra3d.Camera, node tetra3d.INode, color tetra3d.Color) {
+
+	w, h := camera.Size()
+	halfW, halfH := float32(w)/2, float32(h)/2
+
+	nodePos := node.WorldPosition()
+
+	var dx, dy float32
+
+	if debugDepth(camera, nodePos) >= camera.Near() {
+
+		pos := camera.WorldToScreenPixels(nodePos)
+
+		if pos.X >= 0 && pos.Y >= 0 && pos.X < float32(w) && pos.Y < float32(h) {
+			return // The node's on-screen
+		}
+
+		dx, dy = pos.X-halfW, pos.Y-halfH
+
+	} else {
+
+		// Points behind the camera project mirrored, so the direction's taken from the camera's axes instead.
+		diff := nodePos.Sub(camera.WorldPosition())
+		rotation := camera.WorldRotation()
+		dx, dy = diff.Dot(rotation.Right()), -diff.Dot(rotation.Up())
+
+		if dx == 0 && dy == 0 {
+			dy = 1 // Directly behind the camera
+		}
+
+	}
+
+	// Scale the direction so the arrow's tip sits on the rectangle inset from the screen's edges.
+	scale := min((halfW-highlightArrowMargin)/math32.Abs(dx), (halfH-highlightArrowMargin)/math32.Abs(dy))
+	tipX, tipY := halfW+dx*scale, halfH+dy*scale
+
+	angle := math32.Atan2(dy, dx)
+	const length = 16
+
+	c := color.ToNRGBA64()
+	tailX, tailY := tipX-math32.Cos(angle)*length, tipY-math32.Sin(angle)*length
+
+	vector.StrokeLine(screen, tailX, tailY, tipX, tipY, 3, c, true)
+
+	for _, side := range []float32{-1, 1} {
+		wingAngle := angle + math32.Pi + side*math32.Pi/5
+		vector.StrokeLine(screen, tipX, tipY, tipX+math32.Cos(wingAngle)*10, tipY+math32.Sin(wingAngle)*10, 3, c, true)
+	}
+
+	label := fmt.Sprintf("%s (%.1f)", node.Name(), camera.WorldPosition().DistanceTo(nodePos))
+
+	// Keep the label on-screen by drawing it on the side of the arrow facing the screen's center.
+	labelX := tailX - math32.Cos(angle)*8
+	if dx > 0 {
+		labelX -= float32(len(label)) * 7
+	}
+
+	camera.DrawDebugText(screen, label, labelX, tailY-math32.Sin(angle)*8, 1, color)
+
+}
@@ -0,0 +1,753 @@
+package tetraterm
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+	"github.com/rivo/tview"
+)
+
+const (
+	inputQuery = iota
+	inputKeyTap
+	inputKeyHold
+	inputKeyRelease
+	inputStick
+	inputClick
+	inputReleaseAll
+	inputRecordStart
+	inputRecordStop
+	inputRecordFetch
+	inputReplayLoad
+	inputReplayStart
+	inputReplayStop
+)
+
+const (
+	stickLeft = iota
+	stickRight
+)
+
+// inputState is the state of the virtual input driven by the terminal for a single tick.
+type inputState struct {
+	Keys         map[ebiten.Key]bool
+	MouseButtons map[ebiten.MouseButton]bool
+	HasCursor    bool
+	CursorX      int
+	CursorY      int
+	Axes         map[ebiten.StandardGamepadAxis]float64
+}
+
+func newInputState() inputState {
+	return inputState{
+		Keys:         map[ebiten.Key]bool{},
+		MouseButtons: map[ebiten.MouseButton]bool{},
+		Axes:         map[ebiten.StandardGamepadAxis]float64{},
+	}
+}
+
+func (state inputState) clone() inputState {
+
+	newState := newInputState()
+	newState.HasCursor = state.HasCursor
+	newState.CursorX = state.CursorX
+	newState.CursorY = state.CursorY
+
+	for key, pressed := range state.Keys {
+		newState.Keys[key] = pressed
+	}
+	for button, pressed := range state.MouseButtons {
+		newState.MouseButtons[button] = pressed
+	}
+	for axis, value := range state.Axes {
+		newState.Axes[axis] = value
+	}
+
+	return newState
+
+}
+
+// virtualInput is the virtual input layer that the terminal drives, which is combined with the game's real input
+// by Server.IsKeyPressed() and friends.
+type virtualInput struct {
+	mutex    sync.Mutex
+	current  inputState
+	previous inputState
+
+	// Keys and buttons that are tapped are released after the tick they're pressed on.
+	tappedKeys    map[ebiten.Key]bool
+	tappedButtons map[ebiten.MouseButton]bool
+
+	// The real cursor's position when the virtual cursor was set; the virtual cursor is released when the real
+	// cursor moves away from it.
+	realCursorX, realCursorY int
+
+	recording  bool
+	recorded   []inputState
+	replaying  bool
+	replay     []inputState
+	replayTick int
+	// The recording being sent from the terminal to be replayed.
+	loading []inputState
+}
+
+// endTick moves the virtual input on to the next tick, releasing any tapped keys and buttons. This is called on
+// the game thread from Server.Update(), before the terminal's requests for the next tick are applied. tickRan
+// should be false if the game skipped updating this tick because it's paused, so recordings, replays, and taps
+// stay in step with the game's simulation.
+func (input *virtualInput) endTick(tickRan bool) {
+
+	input.mutex.Lock()
+	defer input.mutex.Unlock()
+
+	if input.current.Keys == nil {
+		input.current = newInputState()
+		input.tappedKeys = map[ebiten.Key]bool{}
+		input.tappedButtons = map[ebiten.MouseButton]bool{}
+	}
+
+	if input.recording && tickRan {
+		input.recorded = append(input.recorded, input.combinedState())
+	}
+
+	if input.replaying {
+
+		if !tickRan {
+			return
+		}
+
+		input.previous = input.current
+
+		if input.replayTick >= len(input.replay) {
+			input.replaying = false
+			input.replay = nil
+			input.current = newInputState()
+			return
+		}
+
+		input.current = input.replay[input.replayTick].clone()
+		input.replayTick++
+
+		return
+
+	}
+
+	// Tapped keys and buttons are held until the game runs a tick with them, so taps aren't lost while it's paused.
+	if tickRan {
+
+		input.previous = input.current.clone()
+
+		for key := range input.tappedKeys {
+			delete(input.current.Keys, key)
+		}
+		for button := range input.tappedButtons {
+			delete(input.current.MouseButtons, button)
+		}
+
+		input.tappedKeys = map[ebiten.Key]bool{}
+		input.tappedButtons = map[ebiten.MouseButton]bool{}
+
+	}
+
+	if input.current.HasCursor {
+		if x, y := ebiten.CursorPosition(); x != input.realCursorX || y != input.realCursorY {
+			input.current.HasCursor = false
+		}
+	}
+
+}
+
+// apply applies an input action sent from the terminal; this is called on the game thread.
+func (input *virtualInput) apply(packet *inputPacket) {
+
+	input.mutex.Lock()
+	defer input.mutex.Unlock()
+
+	switch packet.Action {
+
+	case inputKeyTap:
+		for _, key := range packet.Keys {
+			input.current.Keys[key] = true
+			input.tappedKeys[key] = true
+		}
+
+	case inputKeyHold:
+		for _, key := range packet.Keys {
+			input.current.Keys[key] = true
+			delete(input.tappedKeys, key)
+		}
+
+	case inputKeyRelease:
+		for _, key := range packet.Keys {
+			delete(input.current.Keys, key)
+			delete(input.tappedKeys, key)
+		}
+
+	case inputStick:
+
+		horizontal, vertical := ebiten.StandardGamepadAxisLeftStickHorizontal, ebiten.StandardGamepadAxisLeftStickVertical
+		if packet.Stick == stickRight {
+			horizontal, vertical = ebiten.StandardGamepadAxisRightStickHorizontal, ebiten.StandardGamepadAxisRightStickVertical
+		}
+
+		// Centering the stick hands it back to the real gamepad.
+		if packet.X == 0 && packet.Y == 0 {
+			delete(input.current.Axes, horizontal)
+			delete(input.current.Axes, vertical)
+		} else {
+			input.current.Axes[horizontal] = clampAxis(packet.X)
+			input.current.Axes[vertical] = clampAxis(packet.Y)
+		}
+
+	case inputClick:
+		input.current.HasCursor = true
+		input.current.CursorX = packet.CursorX
+		input.current.CursorY = packet.CursorY
+		input.realCursorX, input.realCursorY = ebiten.CursorPosition()
+		input.current.MouseButtons[packet.Button] = true
+		input.tappedButtons[packet.Button] = true
+
+	case inputReleaseAll:
+		input.current = newInputState()
+		input.tappedKeys = map[ebiten.Key]bool{}
+		input.tappedButtons = map[ebiten.MouseButton]bool{}
+
+	case inputRecordStart:
+		input.recording = true
+		input.recorded = nil
+
+	case inputRecordStop:
+		// The recording is kept so the terminal can fetch it.
+		input.recording = false
+
+	case inputRecordFetch:
+		if packet.Offset >= 0 && packet.Offset < len(input.recorded) {
+			end := packet.Offset + recordingChunkSize
+			if end > len(input.recorded) {
+				end = len(input.recorded)
+			}
+			packet.Ticks = input.recorded[packet.Offset:end]
+		}
+
+	case inputReplayLoad:
+		if packet.Offset == 0 {
+			input.loading = nil
+		}
+		input.loading = append(input.loading, packet.Ticks...)
+		// There's no need to send the recording back to the terminal.
+		packet.Ticks = nil
+
+	case inputReplayStart:
+		input.recording = false
+		input.replaying = true
+		input.replay = input.loading
+		input.replayTick = 0
+		input.loading = nil
+		// Requests are applied before the game's tick, so the first recorded tick is used for this one.
+		if len(input.replay) > 0 {
+			input.previous = input.current
+			input.current = input.replay[0].clone()
+			input.replayTick = 1
+		}
+
+	case inputReplayStop:
+		if input.replaying {
+			input.replaying = false
+			input.replay = nil
+			input.current = newInputState()
+		}
+
+	}
+
+}
+
+// combinedState returns the state of both the real and virtual input, as the game sees it through the Server's
+// input functions. This should be called on the game thread with the input's mutex locked.
+func (input *virtualInput) combinedState() inputState {
+
+	state := input.current.clone()
+
+	for _, key := range inpututil.AppendPressedKeys(nil) {
+		state.Keys[key] = true
+	}
+
+	for button := ebiten.MouseButton0; button <= ebiten.MouseButtonMax; button++ {
+		if ebiten.IsMouseButtonPressed(button) {
+			state.MouseButtons[button] = true
+		}
+	}
+
+	if !state.HasCursor {
+		state.HasCursor = true
+		state.CursorX, state.CursorY = ebiten.CursorPosition()
+	}
+
+	// Only the first gamepad's sticks are recorded.
+	if ids := ebiten.AppendGamepadIDs(nil); len(ids) > 0 && ebiten.IsStandardGamepadLayoutAvailable(ids[0]) {
+		for axis := ebiten.StandardGamepadAxis(0); axis <= ebiten.StandardGamepadAxisMax; axis++ {
+			if _, exists := state.Axes[axis]; !exists {
+				state.Axes[axis] = ebiten.StandardGamepadAxisValue(ids[0], axis)
+			}
+		}
+	}
+
+	return state
+
+}
+
+// state returns a copy of the virtual input's current state.
+func (input *virtualInput) state() inputState {
+	input.mutex.Lock()
+	defer input.mutex.Unlock()
+	if input.current.Keys == nil {
+		return newInputState()
+	}
+	return input.current.clone()
+}
+
+func clampAxis(value float64) float64 {
+	if value < -1 {
+		return -1
+	}
+	if value > 1 {
+		return 1
+	}
+	return value
+}
+
+// IsKeyPressed returns if the key given is pressed, either on the keyboard or through the terminal's virtual input.
+// Using this (and the other input functions on the Server) in place of ebiten's allows the terminal to press keys
+// in the game while its window is unfocused, from the Input pane (F11) or with the console's key command.
+func (server *Server) IsKeyPressed(key ebiten.Key) bool {
+	server.input.mutex.Lock()
+	defer server.input.mutex.Unlock()
+	return (!server.input.replaying && ebiten.IsKeyPressed(key)) || server.input.current.Keys[key]
+}
+
+// IsKeyJustPressed returns if the key given was pressed this tick, either on the keyboard or through the terminal's
+// virtual input; this wraps inpututil.IsKeyJustPressed().
+func (server *Server) IsKeyJustPressed(key ebiten.Key) bool {
+	server.input.mutex.Lock()
+	defer server.input.mutex.Unlock()
+	return (!server.input.replaying && inpututil.IsKeyJustPressed(key)) || (server.input.current.Keys[key] && !server.input.previous.Keys[key])
+}
+
+// IsKeyJustReleased returns if the key given was released this tick, either on the keyboard or through the
+// terminal's virtual input; this wraps inpututil.IsKeyJustReleased().
+func (server *Server) IsKeyJustReleased(key ebiten.Key) bool {
+	server.input.mutex.Lock()
+	defer server.input.mutex.Unlock()
+	return (!server.input.replaying && inpututil.IsKeyJustReleased(key)) || (!server.input.current.Keys[key] && server.input.previous.Keys[key])
+}
+
+// IsMouseButtonPressed returns if the mouse button given is pressed, either on the mouse or through a click sent
+// from the terminal.
+func (server *Server) IsMouseButtonPressed(button ebiten.MouseButton) bool {
+	server.input.mutex.Lock()
+	defer server.input.mutex.Unlock()
+	return (!server.input.replaying && ebiten.IsMouseButtonPressed(button)) || server.input.current.MouseButtons[button]
+}
+
+// IsMouseButtonJustPressed returns if the mouse button given was pressed this tick, either on the mouse or through
+// a click sent from the terminal; this wraps inpututil.IsMouseButtonJustPressed().
+func (server *Server) IsMouseButtonJustPressed(button ebiten.MouseButton) bool {
+	server.input.mutex.Lock()
+	defer server.input.mutex.Unlock()
+	return (!server.input.replaying && inpututil.IsMouseButtonJustPressed(button)) || (server.input.current.MouseButtons[button] && !server.input.previous.MouseButtons[button])
+}
+
+// IsMouseButtonJustReleased returns if the mouse button given was released this tick, either on the mouse or
+// through a click sent from the terminal; this wraps inpututil.IsMouseButtonJustReleased().
+func (server *Server) IsMouseButtonJustReleased(button ebiten.MouseButton) bool {
+	server.input.mutex.Lock()
+	defer server.input.mutex.Unlock()
+	return (!server.input.replaying && inpututil.IsMouseButtonJustReleased(button)) || (!server.input.current.MouseButtons[button] && server.input.previous.MouseButtons[button])
+}
+
+// CursorPosition returns the position of the mouse cursor. After a click is sent from the terminal, this returns
+// the position clicked until the real mouse cursor moves.
+func (server *Server) CursorPosition() (x, y int) {
+	server.input.mutex.Lock()
+	defer server.input.mutex.Unlock()
+	if server.input.current.HasCursor {
+		return server.input.current.CursorX, server.input.current.CursorY
+	}
+	return ebiten.CursorPosition()
+}
+
+// StandardGamepadAxisValue returns the value of the gamepad axis given, preferring the virtual gamepad stick moved
+// from the terminal over the real gamepad's. The virtual stick applies to any gamepad ID, so it can be read
+// with an ID of 0 even if no gamepad is connected.
+func (server *Server) StandardGamepadAxisValue(id ebiten.GamepadID, axis ebiten.StandardGamepadAxis) float64 {
+	server.input.mutex.Lock()
+	defer server.input.mutex.Unlock()
+	if value, exists := server.input.current.Axes[axis]; exists || server.input.replaying {
+		return value
+	}
+	return ebiten.StandardGamepadAxisValue(id, axis)
+}
+
+// parseKeys parses key names (like A, Space, ArrowUp, or ShiftLeft) as ebiten keys.
+func parseKeys(names []string) ([]ebiten.Key, error) {
+
+	keys := []ebiten.Key{}
+
+	for _, name := range names {
+		var key ebiten.Key
+		if err := key.UnmarshalText([]byte(name)); err != nil {
+			return nil, errors.New("unknown key " + strconv.Quote(name) + "; keys are named as in ebiten (i.e. A, 1, Space, Enter, ArrowUp, ShiftLeft)")
+		}
+		keys = append(keys, key)
+	}
+
+	return keys, nil
+
+}
+
+// parseMouseButton parses a mouse button name (left, right, or middle).
+func parseMouseButton(name string) (ebiten.MouseButton, error) {
+
+	switch strings.ToLower(name) {
+	case "left":
+		return ebiten.MouseButtonLeft, nil
+	case "right":
+		return ebiten.MouseButtonRight, nil
+	case "middle":
+		return ebiten.MouseButtonMiddle, nil
+	}
+
+	return 0, errors.New("unknown mouse button " + strconv.Quote(name) + "; buttons are left, right, and middle")
+
+}
+
+// sendInput sends an input action to the game, returning the resulting virtual input state.
+func (display *Display) sendInput(packet *inputPacket) (inputState, error) {
+
+	res, err := display.sendRequest(packet)
+	if err != nil {
+		return inputState{}, err
+	}
+
+	return res.(*inputPacket).State, nil
+
+}
+
+// runKeyCommand runs the key console command (key <tap|hold|release> <keys...>).
+func (display *Display) runKeyCommand(args []string) (string, error) {
+
+	if len(args) < 2 {
+		return "", errors.New("usage: key <tap|hold|release> <keys...>")
+	}
+
+	var action int
+
+	switch strings.ToLower(args[0]) {
+	case "tap":
+		action = inputKeyTap
+	case "hold":
+		action = inputKeyHold
+	case "release":
+		action = inputKeyRelease
+	default:
+		return "", errors.New("usage: key <tap|hold|release> <keys...>")
+	}
+
+	keys, err := parseKeys(args[1:])
+	if err != nil {
+		return "", err
+	}
+
+	packet := newInputPacket(action)
+	packet.Keys = keys
+
+	state, err := display.sendInput(packet)
+	if err != nil {
+		return "", err
+	}
+
+	return strings.ToLower(args[0]) + " " + strings.Join(args[1:], " ") + "; " + describeInputState(state), nil
+
+}
+
+// describeInputState returns a single-line description of the virtual input state given.
+func describeInputState(state inputState) string {
+
+	keys := []string{}
+	for key := range state.Keys {
+		keys = append(keys, key.String())
+	}
+	sort.Strings(keys)
+
+	text := "held keys: none"
+	if len(keys) > 0 {
+		text = "held keys: " + strings.Join(keys, ", ")
+	}
+
+	for _, stick := range []struct {
+		name                 string
+		horizontal, vertical ebiten.StandardGamepadAxis
+	}{
+		{"left", ebiten.StandardGamepadAxisLeftStickHorizontal, ebiten.StandardGamepadAxisLeftStickVertical},
+		{"right", ebiten.StandardGamepadAxisRightStickHorizontal, ebiten.StandardGamepadAxisRightStickVertical},
+	} {
+		if _, exists := state.Axes[stick.horizontal]; exists {
+			text += fmt.Sprintf(", %s stick: %.2f %.2f", stick.name, state.Axes[stick.horizontal], state.Axes[stick.vertical])
+		}
+	}
+
+	if state.HasCursor {
+		text += fmt.Sprintf(", cursor: %d %d", state.CursorX, state.CursorY)
+	}
+
+	return text
+
+}
+
+// tcellRuneKeys maps the punctuation runes typed into the Input pane to ebiten keys.
+var tcellRuneKeys = map[rune]ebiten.Key{
+	' ':  ebiten.KeySpace,
+	'-':  ebiten.KeyMinus,
+	'=':  ebiten.KeyEqual,
+	'[':  ebiten.KeyBracketLeft,
+	']':  ebiten.KeyBracketRight,
+	'\\': ebiten.KeyBackslash,
+	';':  ebiten.KeySemicolon,
+	'\'': ebiten.KeyQuote,
+	',':  ebiten.KeyComma,
+	'.':  ebiten.KeyPeriod,
+	'/':  ebiten.KeySlash,
+	'`':  ebiten.KeyBackquote,
+}
+
+// tcellKeys maps the special keys typed into the Input pane to ebiten keys.
+var tcellKeys = map[tcell.Key]ebiten.Key{
+	tcell.KeyEnter:      ebiten.KeyEnter,
+	tcell.KeyTab:        ebiten.KeyTab,
+	tcell.KeyBackspace:  ebiten.KeyBackspace,
+	tcell.KeyBackspace2: ebiten.KeyBackspace,
+	tcell.KeyDelete:     ebiten.KeyDelete,
+	tcell.KeyInsert:     ebiten.KeyInsert,
+	tcell.KeyUp:         ebiten.KeyArrowUp,
+	tcell.KeyDown:       ebiten.KeyArrowDown,
+	tcell.KeyLeft:       ebiten.KeyArrowLeft,
+	tcell.KeyRight:      ebiten.KeyArrowRight,
+	tcell.KeyHome:       ebiten.KeyHome,
+	tcell.KeyEnd:        ebiten.KeyEnd,
+	tcell.KeyPgUp:       ebiten.KeyPageUp,
+	tcell.KeyPgDn:       ebiten.KeyPageDown,
+}
+
+// tcellEventKeys returns the ebiten keys that correspond to a key typed into the terminal; uppercase letters
+// and other shifted characters also include the left shift key.
+func tcellEventKeys(event *tcell.EventKey) []ebiten.Key {
+
+	if key, exists := tcellKeys[event.Key()]; exists {
+		return []ebiten.Key{key}
+	}
+
+	if event.Key() != tcell.KeyRune {
+		return nil
+	}
+
+	r := event.Rune()
+
+	if key, exists := tcellRuneKeys[r]; exists {
+		return []ebiten.Key{key}
+	}
+
+	var key ebiten.Key
+	if err := key.UnmarshalText([]byte(string(r))); err != nil {
+		return nil
+	}
+
+	if r >= 'A' && r <= 'Z' {
+		return []ebiten.Key{ebiten.KeyShiftLeft, key}
+	}
+
+	return []ebiten.Key{key}
+
+}
+
+// initInputPane creates the Input tool pane, which forwards keys typed into it to the game through its
+// virtual input layer, and records and replays the game's input.
+func (display *Display) initInputPane() {
+
+	view := tview.NewTextView()
+	view.SetDynamicColors(true)
+	view.SetWrap(true)
+	view.SetBackgroundColor(tcell.ColorDefault)
+	view.SetBorder(true)
+	view.SetTitle("[ Input ]")
+
+	display.addToolPane("input", tcell.KeyF11, view)
+
+	help := "[gray]While this pane is focused, keys typed are tapped in the game (through Server.IsKeyPressed() and friends).\n" +
+		"Alt+Key: Hold / Release Key, Ctrl+X: Release Everything, Esc: Leave Pane\n" +
+		"Ctrl+E: Start / Stop Recording, Ctrl+O: Replay Recording, Ctrl+K: Stop Replay\n" +
+		"Console: key <tap|hold|release> <keys...>, stick <left|right> <x> <y>, click <x> <y> [button], record, replay[-]"
+
+	lastAction := ""
+	state := newInputState()
+	status := newInputPacket(inputQuery)
+
+	refresh := func() {
+		text := "Virtual Input: " + tview.Escape(describeInputState(state)) + "\n" + formatRecordingStatus(status)
+		if lastAction != "" {
+			text += "\nLast Action: [yellow]" + tview.Escape(lastAction) + "[-]"
+		}
+		view.SetText(text + "\n\n" + help)
+	}
+
+	refresh()
+
+	// setLastAction displays the outcome of an action; this is safe to call from any goroutine.
+	setLastAction := func(description string, err error) {
+		display.App.QueueUpdate(func() {
+			if err != nil {
+				lastAction = description + " (failed: " + err.Error() + ")"
+			} else {
+				lastAction = description
+			}
+			refresh()
+		})
+	}
+
+	send := func(packet *inputPacket, description string) {
+		go func() {
+			newState, err := display.sendInput(packet)
+			if err == nil {
+				display.App.QueueUpdate(func() { state = newState })
+			}
+			setLastAction(description, err)
+		}()
+	}
+
+	held := map[ebiten.Key]bool{}
+
+	view.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+
+		switch event.Key() {
+
+		case tcell.KeyCtrlX:
+			held = map[ebiten.Key]bool{}
+			send(newInputPacket(inputReleaseAll), "released everything")
+			return nil
+
+		case tcell.KeyCtrlE:
+			recording := status.Recording
+			go func() {
+				if recording {
+					path, err := display.stopInputRecording()
+					setLastAction("saved recording to "+path, err)
+				} else {
+					setLastAction("started recording", display.startInputRecording())
+				}
+			}()
+			return nil
+
+		case tcell.KeyCtrlO:
+			display.promptValue("Replay Input Recording (File Path)", "", func(path string) error {
+				ticks, err := display.replayInputRecording(path)
+				if err == nil {
+					setLastAction("replaying "+strconv.Itoa(ticks)+" ticks from "+path, nil)
+				}
+				return err
+			})
+			return nil
+
+		case tcell.KeyCtrlK:
+			go func() {
+				setLastAction("stopped replay", display.stopInputReplay())
+			}()
+			return nil
+
+		}
+
+		keys := tcellEventKeys(event)
+		if len(keys) == 0 {
+			return event
+		}
+
+		names := []string{}
+		for _, key := range keys {
+			names = append(names, key.String())
+		}
+
+		packet := newInputPacket(inputKeyTap)
+		packet.Keys = keys
+		description := "tapped " + strings.Join(names, "+")
+
+		// Terminals don't report key releases, so keys are held and released by pressing them with Alt.
+		if event.Modifiers()&tcell.ModAlt != 0 {
+			key := keys[len(keys)-1]
+			packet.Keys = []ebiten.Key{key}
+			if held[key] {
+				delete(held, key)
+				packet.Action = inputKeyRelease
+				description = "released " + key.String()
+			} else {
+				held[key] = true
+				packet.Action = inputKeyHold
+				description = "holding " + key.String()
+			}
+		}
+
+		send(packet, description)
+
+		return nil
+
+	})
+
+	// The virtual input's state is polled while the pane is visible, as it can also be changed from the console,
+	// and to show the progress of recordings and replays.
+	go func() {
+
+		for {
+
+			time.Sleep(time.Millisecond * 250)
+
+			if !display.running.Load() {
+				return
+			}
+
+			if !display.toolPaneVisible("input") {
+				continue
+			}
+
+			res, err := display.sendRequest(newInputPacket(inputQuery))
+			if err != nil {
+				continue
+			}
+
+			packet := res.(*inputPacket)
+
+			display.App.QueueUpdate(func() {
+
+				if status.Replaying && !packet.Replaying && packet.ReplayTick >= status.ReplayLength {
+					lastAction = "replay finished"
+				}
+
+				held = map[ebiten.Key]bool{}
+				for key := range packet.State.Keys {
+					held[key] = true
+				}
+
+				state = packet.State
+				status = packet
+				refresh()
+
+			})
+
+		}
+
+	}()
+
+}
@@ -0,0 +1,172 @@
+package tetraterm
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/solarlune/tetra3d"
+)
+
+// The type given to the top-level values of inspector sections, to tell them apart from node data.
+const inspectorSectionType = "inspector"
+
+// The section that inspector fields without a section are placed in.
+const defaultInspectorSection = "Inspector"
+
+// InspectorField represents a field contributed by an inspector to the Node Properties pane for a node;
+// see Server.RegisterInspector().
+type InspectorField struct {
+	Section string // The section the field is grouped under; if empty, the field is placed in an "Inspector" section
+	Name    string // The name of the field
+	Value   any    // The value of the field; structs, slices and maps are displayed as expandable trees
+	// Set, if not nil, allows the field to be edited from the terminal. It's called on the game thread with the
+	// text entered in the terminal; if it returns an error, the error is displayed in the terminal.
+	Set func(text string) error
+}
+
+// Inspector is a function that returns extra fields to display in the Node Properties pane for the given node.
+// Inspectors can return nil for nodes they don't apply to.
+type Inspector func(node tetra3d.INode) []InspectorField
+
+// RegisterInspector registers a function to contribute extra fields to the Node Properties pane of the terminal
+// for specific nodes, like displaying AI state for enemies. The inspector is called on the game thread during
+// Server.Update() while the terminal is displaying the selected node.
+//
+// Example usage:
+//
+//	server.RegisterInspector(func(node tetra3d.INode) []tetraterm.InspectorField {
+//		enemy, ok := node.Data().(*Enemy)
+//		if !ok {
+//			return nil
+//		}
+//		return []tetraterm.InspectorField{
+//			{Section: "AI", Name: "State", Value: enemy.State.String()},
+//			{Section: "AI", Name: "Health", Value: enemy.Health, Set: func(text string) error {
+//				health, err := strconv.Atoi(text)
+//				if err != nil {
+//					return err
+//				}
+//				enemy.Health = health
+//				return nil
+//			}},
+//		}
+//	})
+func (server *Server) RegisterInspector(inspector Inspector) {
+	server.nodeData.mutex.Lock()
+	server.nodeData.inspectors = append(server.nodeData.inspectors, inspector)
+	server.nodeData.mutex.Unlock()
+}
+
+// inspectNode runs the inspectors given on the node, returning the fields they contribute. An inspector that
+// panics contributes a field describing the panic instead.
+func inspectNode(inspectors []Inspector, node tetra3d.INode) []InspectorField {
+
+	fields := []InspectorField{}
+
+	for i, inspector := range inspectors {
+
+		func() {
+
+			defer func() {
+				if r := recover(); r != nil {
+					fields = append(fields, InspectorField{
+						Name:  "Inspector " + strconv.Itoa(i),
+						Value: fmt.Sprintf("panic: %v", r),
+					})
+				}
+			}()
+
+			fields = append(fields, inspector(node)...)
+
+		}()
+
+	}
+
+	for i := range fields {
+		if fields[i].Section == "" {
+			fields[i].Section = defaultInspectorSection
+		}
+	}
+
+	return fields
+
+}
+
+// newInspectorSections serializes the fields given, grouped into a top-level value for each section.
+func newInspectorSections(fields []InspectorField) []valueNode {
+
+	sections := []valueNode{}
+
+	for _, field := range fields {
+
+		value := newValueTree(field.Name, field.Value)
+		clearEditable(&value)
+		value.Editable = field.Set != nil && len(value.Children) == 0
+
+		found := false
+		for s := range sections {
+			if sections[s].Name == field.Section {
+				sections[s].Children = append(sections[s].Children, value)
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			sections = append(sections, valueNode{
+				Name:     field.Section,
+				Type:     inspectorSectionType,
+				Children: []valueNode{value},
+			})
+		}
+
+	}
+
+	return sections
+
+}
+
+// clearEditable marks the value and all of its children as not editable; inspector fields can only be
+// edited through their setters.
+func clearEditable(value *valueNode) {
+	value.Editable = false
+	for i := range value.Children {
+		clearEditable(&value.Children[i])
+	}
+}
+
+// setSelectedNodeInspectorField sets the named inspector field of the selected node using its setter.
+func (server *Server) setSelectedNodeInspectorField(nodeID uint32, section, name, text string) error {
+
+	return server.runOnGameThreadAndWait(func() (err error) {
+
+		// A panicking setter shouldn't crash the game from a terminal edit.
+		defer func() {
+			if r := recover(); r != nil {
+				err = fmt.Errorf("panic: %v", r)
+			}
+		}()
+
+		if server.selectedNode == nil || server.selectedNode.ID() != nodeID {
+			return errors.New("the node is no longer selected")
+		}
+
+		server.nodeData.mutex.Lock()
+		inspectors := append([]Inspector{}, server.nodeData.inspectors...)
+		server.nodeData.mutex.Unlock()
+
+		for _, field := range inspectNode(inspectors, server.selectedNode) {
+			if field.Section == section && field.Name == name {
+				if field.Set == nil {
+					return errors.New(name + " can't be edited")
+				}
+				return field.Set(text)
+			}
+		}
+
+		return errors.New("no inspector field named " + section + "." + name)
+
+	})
+
+}
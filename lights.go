@@ -0,0 +1,482 @@
+package tetraterm
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+	"github.com/solarlune/tetra3d"
+)
+
+const (
+	lightQuery = iota
+	lightToggle
+	lightSolo
+	lightAllOff
+	lightAllOn
+	lightRestore
+	lightSetColor
+	lightSetEnergy
+	lightSetRange
+)
+
+// lightInfo describes a light in the active scene for the Lights pane.
+type lightInfo struct {
+	NodeID   uint32
+	Name     string
+	Type     tetra3d.NodeType
+	On       bool
+	Color    tetra3d.Color
+	Energy   float32
+	Range    float32
+	HasRange bool // Whether the light has a range (i.e. it's a point light)
+	InWorld  bool // Whether the light is the World's ambient light, rather than a node in the scene tree
+}
+
+// lightStates holds the on / off states the lights had before they were soloed or all turned off from the
+// Lights pane, so they can be restored.
+type lightStates struct {
+	saved map[tetra3d.ILight]bool
+}
+
+// sceneLights returns the lights in the active scene, including its World's ambient light.
+func (server *Server) sceneLights() []tetra3d.ILight {
+
+	lights := []tetra3d.ILight{}
+
+	for _, node := range server.activeScene.Root.SearchTree().INodes() {
+		if light, ok := node.(tetra3d.ILight); ok {
+			lights = append(lights, light)
+		}
+	}
+
+	if ambient := server.worldAmbientLight(); ambient != nil {
+		lights = append(lights, ambient)
+	}
+
+	return lights
+
+}
+
+// worldAmbientLight returns the active scene's World's ambient light, if it has one that isn't also in the
+// scene tree.
+func (server *Server) worldAmbientLight() tetra3d.ILight {
+
+	world := server.activeScene.World
+
+	if world == nil || world.AmbientLight == nil || world.AmbientLight.IsDescendantOf(server.activeScene.Root) {
+		return nil
+	}
+
+	return world.AmbientLight
+
+}
+
+// handleLights applies the light action given in the packet to the active scene's lights, filling the packet
+// out with the lights afterwards. This should be run on the game thread.
+func (server *Server) handleLights(packet *lightsPacket) error {
+
+	if server.activeScene == nil {
+		return errors.New("no scene has been passed to Server.Update() yet")
+	}
+
+	lights := server.sceneLights()
+
+	var target tetra3d.ILight
+
+	for _, light := range lights {
+		if light.ID() == packet.NodeID {
+			target = light
+		}
+	}
+
+	switch packet.Action {
+	case lightToggle, lightSolo, lightSetColor, lightSetEnergy, lightSetRange:
+		if target == nil {
+			return errors.New("no light with ID " + strconv.Itoa(int(packet.NodeID)))
+		}
+	}
+
+	// The lights' states are saved before the first solo or all off, so they can be restored afterwards.
+	save := func() {
+		if server.lightStates.saved == nil {
+			server.lightStates.saved = map[tetra3d.ILight]bool{}
+			for _, light := range lights {
+				server.lightStates.saved[light] = light.IsOn()
+			}
+		}
+	}
+
+	switch packet.Action {
+
+	case lightToggle:
+		target.SetOn(!target.IsOn())
+
+	case lightSolo:
+		save()
+		for _, light := range lights {
+			light.SetOn(light == target)
+		}
+
+	case lightAllOff:
+		save()
+		for _, light := range lights {
+			light.SetOn(false)
+		}
+
+	case lightAllOn:
+		for _, light := range lights {
+			light.SetOn(true)
+		}
+
+	case lightRestore:
+		for light, on := range server.lightStates.saved {
+			light.SetOn(on)
+		}
+		server.lightStates.saved = nil
+
+	case lightSetColor:
+		target.SetColor(packet.Color)
+
+	case lightSetEnergy:
+		target.SetEnergy(packet.Energy)
+
+	case lightSetRange:
+		point, ok := target.(*tetra3d.PointLight)
+		if !ok {
+			return errors.New(target.Name() + " isn't a point light, so it has no range")
+		}
+		if packet.Range < 0 {
+			return errors.New("the range can't be negative")
+		}
+		point.Range = packet.Range
+
+	}
+
+	packet.Lights = []lightInfo{}
+
+	ambient := server.worldAmbientLight()
+
+	for _, light := range lights {
+
+		info := lightInfo{
+			NodeID:  light.ID(),
+			Name:    light.Name(),
+			Type:    light.Type(),
+			On:      light.IsOn(),
+			Color:   light.Color(),
+			Energy:  light.Energy(),
+			InWorld: light == ambient,
+		}
+
+		if point, ok := light.(*tetra3d.PointLight); ok {
+			info.Range = point.Range
+			info.HasRange = true
+		}
+
+		packet.Lights = append(packet.Lights, info)
+
+	}
+
+	packet.CanRestore = server.lightStates.saved != nil
+
+	return nil
+
+}
+
+// lightTypeNames are the names shown in the Lights pane for each type of light.
+var lightTypeNames = map[tetra3d.NodeType]string{
+	tetra3d.NodeTypePointLight:       "Point",
+	tetra3d.NodeTypeDirectionalLight: "Directional",
+	tetra3d.NodeTypeAmbientLight:     "Ambient",
+	tetra3d.NodeTypeCubeLight:        "Cube",
+}
+
+// initLightPane creates the Lights tool pane, which lists the lights in the active scene, allowing them to be
+// turned on and off, soloed, and edited.
+func (display *Display) initLightPane() {
+
+	list := tview.NewList()
+	list.SetBackgroundColor(tcell.ColorDefault)
+	list.SetMainTextColor(tcell.ColorWhite)
+	list.SetSecondaryTextColor(tcell.ColorGray)
+
+	status := tview.NewTextView()
+	status.SetDynamicColors(true)
+	status.SetWrap(true)
+	status.SetBackgroundColor(tcell.ColorDefault)
+
+	pane := tview.NewFlex()
+	pane.SetDirection(tview.FlexRow)
+	pane.SetBorder(true)
+	pane.SetTitle("[ Lights ]")
+	pane.AddItem(list, 0, 1, true)
+	pane.AddItem(status, 2, 0, false)
+
+	display.addToolPane("lights", tcell.KeyF15, pane)
+
+	const help = "[gray]Enter: On / Off, S: Solo, M: All Off, A: All On, R: Restore, C: Color, E: Energy, G: Range, T: Select in Tree[-]"
+	status.SetText(help)
+
+	lights := []lightInfo{}
+	canRestore := false
+
+	refresh := func() {
+
+		current := list.GetCurrentItem()
+
+		list.Clear()
+
+		on := 0
+
+		for _, light := range lights {
+
+			c := light.Color.ToNRGBA64()
+			swatch := fmt.Sprintf("[#%02x%02x%02x]■[-]", c.R>>8, c.G>>8, c.B>>8)
+
+			text := "[green]●[-] " + swatch + " " + tview.Escape(light.Name)
+			if light.On {
+				on++
+			} else {
+				text = "[gray]○[-] " + swatch + " [gray]" + tview.Escape(light.Name) + " (off)[-]"
+			}
+
+			typeName, exists := lightTypeNames[light.Type]
+			if !exists {
+				typeName = string(light.Type)
+			}
+
+			if light.InWorld {
+				typeName += " (World)"
+			}
+
+			secondary := fmt.Sprintf("%s, color %s, energy %s", typeName, formatColorText(light.Color), strconv.FormatFloat(float64(light.Energy), 'f', -1, 32))
+			if light.HasRange {
+				if light.Range > 0 {
+					secondary += ", range " + strconv.FormatFloat(float64(light.Range), 'f', -1, 32)
+				} else {
+					secondary += ", no range (inverse square falloff)"
+				}
+			}
+
+			list.AddItem(text, tview.Escape(secondary), 0, nil)
+
+		}
+
+		if len(lights) == 0 {
+			list.AddItem("[gray]The active scene has no lights.[-]", "", 0, nil)
+		}
+
+		title := fmt.Sprintf("[ Lights (%d / %d On) ]", on, len(lights))
+		if canRestore {
+			title = fmt.Sprintf("[ Lights (%d / %d On; R to Restore) ]", on, len(lights))
+		}
+		pane.SetTitle(title)
+
+		if current < list.GetItemCount() {
+			list.SetCurrentItem(current)
+		}
+
+	}
+
+	send := func(packet *lightsPacket) {
+		go func() {
+
+			res, err := display.sendRequest(packet)
+
+			display.App.QueueUpdate(func() {
+				if err != nil {
+					status.SetText("[red]" + tview.Escape(err.Error()) + "[-]\n" + help)
+					return
+				}
+				if errText := res.(*lightsPacket).Error; errText != "" {
+					status.SetText("[red]" + tview.Escape(errText) + "[-]\n" + help)
+					return
+				}
+				status.SetText(help)
+				lights = res.(*lightsPacket).Lights
+				canRestore = res.(*lightsPacket).CanRestore
+				refresh()
+			})
+
+		}()
+	}
+
+	selected := func() (lightInfo, bool) {
+		index := list.GetCurrentItem()
+		if index < 0 || index >= len(lights) {
+			return lightInfo{}, false
+		}
+		return lights[index], true
+	}
+
+	// edit prompts for a value for the selected light, sending it with the action given once it's parsed.
+	edit := func(action int, title, initial string, parse func(text string, packet *lightsPacket) error) {
+
+		light, ok := selected()
+		if !ok {
+			return
+		}
+
+		display.promptValue(title+" of "+light.Name, initial, func(text string) error {
+
+			packet := newLightsPacket(action)
+			packet.NodeID = light.NodeID
+
+			if err := parse(text, packet); err != nil {
+				return err
+			}
+
+			res, err := display.sendRequest(packet)
+			if err != nil {
+				return err
+			}
+
+			if errText := res.(*lightsPacket).Error; errText != "" {
+				return errors.New(errText)
+			}
+
+			// Prompts are submitted on the UI goroutine, so the list can be refreshed directly.
+			lights = res.(*lightsPacket).Lights
+			canRestore = res.(*lightsPacket).CanRestore
+			refresh()
+
+			return nil
+
+		})
+
+	}
+
+	parseNumber := func(text string) (float32, error) {
+		value, err := strconv.ParseFloat(text, 32)
+		if err != nil {
+			return 0, errors.New("couldn't read number " + strconv.Quote(text))
+		}
+		return float32(value), nil
+	}
+
+	list.SetSelectedFunc(func(index int, mainText, secondaryText string, shortcut rune) {
+		if light, ok := selected(); ok {
+			packet := newLightsPacket(lightToggle)
+			packet.NodeID = light.NodeID
+			send(packet)
+		}
+	})
+
+	list.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+
+		switch event.Rune() {
+
+		case 's', 'S':
+			if light, ok := selected(); ok {
+				packet := newLightsPacket(lightSolo)
+				packet.NodeID = light.NodeID
+				send(packet)
+			}
+			return nil
+
+		case 'm', 'M':
+			send(newLightsPacket(lightAllOff))
+			return nil
+
+		case 'a', 'A':
+			send(newLightsPacket(lightAllOn))
+			return nil
+
+		case 'r', 'R':
+			send(newLightsPacket(lightRestore))
+			return nil
+
+		case 'c', 'C':
+			if light, ok := selected(); ok {
+				edit(lightSetColor, "Color (#rrggbb or R G B)", formatColorText(light.Color), func(text string, packet *lightsPacket) error {
+					color, err := parseColorText(text, light.Color.A)
+					packet.Color = color
+					return err
+				})
+			}
+			return nil
+
+		case 'e', 'E':
+			if light, ok := selected(); ok {
+				edit(lightSetEnergy, "Energy", strconv.FormatFloat(float64(light.Energy), 'f', -1, 32), func(text string, packet *lightsPacket) error {
+					value, err := parseNumber(text)
+					packet.Energy = value
+					return err
+				})
+			}
+			return nil
+
+		case 'g', 'G':
+			if light, ok := selected(); ok {
+				edit(lightSetRange, "Range (0 = no range)", strconv.FormatFloat(float64(light.Range), 'f', -1, 32), func(text string, packet *lightsPacket) error {
+					value, err := parseNumber(text)
+					packet.Range = value
+					return err
+				})
+			}
+			return nil
+
+		case 't', 'T':
+			if light, ok := selected(); ok {
+				if light.InWorld {
+					status.SetText("[red]The World's ambient light isn't in the node tree.[-]\n" + help)
+					return nil
+				}
+				node := matchedSceneNode{sceneNode: sceneNode{NodeID: light.NodeID}, ancestors: sceneTreeAncestors(display.currentSceneTree, light.NodeID)}
+				go func() {
+					if err := display.selectSceneNode(node); err != nil {
+						display.App.QueueUpdate(func() { status.SetText("[red]" + tview.Escape(err.Error()) + "[-]\n" + help) })
+					}
+				}()
+			}
+			return nil
+
+		}
+
+		return event
+
+	})
+
+	refresh()
+
+	go func() {
+
+		layout := ""
+
+		for {
+
+			time.Sleep(time.Millisecond * 500)
+
+			if !display.running.Load() {
+				return
+			}
+
+			if !display.toolPaneVisible("lights") {
+				continue
+			}
+
+			res, err := display.sendRequest(newLightsPacket(lightQuery))
+			if err != nil || res.(*lightsPacket).Error != "" {
+				continue
+			}
+
+			packet := res.(*lightsPacket)
+
+			if newLayout := fmt.Sprint(packet.Lights, packet.CanRestore); newLayout != layout {
+				layout = newLayout
+				display.App.QueueUpdate(func() {
+					lights = packet.Lights
+					canRestore = packet.CanRestore
+					refresh()
+				})
+			}
+
+		}
+
+	}()
+
+}
@@ -0,0 +1,628 @@
+package tetraterm
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+	"github.com/solarlune/tetra3d"
+)
+
+// LogNodeKey is the key of the log attribute that links a log record to a node; the Log pane allows jumping to
+// the node of a record that has it. Its value can be a tetra3d.INode, or a uint32 node ID. See LogNode().
+const LogNodeKey = "node"
+
+// LogNode returns a log attribute linking a log record to the given node, so it can be selected from the
+// terminal's Log pane.
+//
+// Example usage:
+//
+//	logger.Warn("fell out of the level", tetraterm.LogNode(player))
+func LogNode(node tetra3d.INode) slog.Attr {
+	return slog.Any(LogNodeKey, node)
+}
+
+// The maximum number of log records the Server holds onto for the terminal.
+const logBufferSize = 2000
+
+// The maximum number of log records sent to the terminal in a single packet.
+const logPacketSize = 500
+
+// The maximum number of log records the Log pane holds onto.
+const logPaneSize = 5000
+
+type logRecord struct {
+	Seq     uint64
+	Time    time.Time
+	Level   slog.Level
+	Message string
+	Attrs   string
+	NodeID  uint32
+	HasNode bool
+}
+
+// logBuffer holds the most recent log records written to the Server through its LogWriter() or LogHandler().
+type logBuffer struct {
+	mutex   sync.Mutex
+	records []logRecord
+	nextSeq uint64
+	session int64 // Identifies this run of the game, so the terminal can tell when it's been restarted
+}
+
+func (logs *logBuffer) add(record logRecord) {
+
+	logs.mutex.Lock()
+	defer logs.mutex.Unlock()
+
+	logs.nextSeq++
+	record.Seq = logs.nextSeq
+
+	logs.records = append(logs.records, record)
+
+	if len(logs.records) > logBufferSize {
+		logs.records = append(logs.records[:0], logs.records[len(logs.records)-logBufferSize:]...)
+	}
+
+}
+
+// since returns the records logged after the record with the given sequence number, up to logPacketSize of them.
+func (logs *logBuffer) since(seq uint64) []logRecord {
+
+	logs.mutex.Lock()
+	defer logs.mutex.Unlock()
+
+	out := []logRecord{}
+
+	for _, record := range logs.records {
+		if record.Seq > seq {
+			out = append(out, record)
+			if len(out) >= logPacketSize {
+				break
+			}
+		}
+	}
+
+	return out
+
+}
+
+// recent returns the last count records logged.
+func (logs *logBuffer) recent(count int) []logRecord {
+
+	logs.mutex.Lock()
+	defer logs.mutex.Unlock()
+
+	if count > len(logs.records) {
+		count = len(logs.records)
+	}
+
+	return append([]logRecord{}, logs.records[len(logs.records)-count:]...)
+
+}
+
+// LogWriter returns an io.Writer that forwards each line written to it to the terminal's Log pane. Lines are
+// logged at the Info level, unless they contain a level in the style of slog's TextHandler (i.e. level=WARN).
+// To keep logging to stdout as well, combine it with os.Stdout using io.MultiWriter().
+//
+// Example usage:
+//
+//	log.SetOutput(io.MultiWriter(os.Stdout, server.LogWriter()))
+func (server *Server) LogWriter() io.Writer {
+	return &logWriter{logs: &server.logs}
+}
+
+type logWriter struct {
+	mutex   sync.Mutex
+	logs    *logBuffer
+	partial string
+}
+
+func (writer *logWriter) Write(p []byte) (int, error) {
+
+	writer.mutex.Lock()
+	defer writer.mutex.Unlock()
+
+	text := writer.partial + string(p)
+	lines := strings.Split(text, "\n")
+
+	// The last element is either empty or an unfinished line, which is held until the rest of it is written.
+	writer.partial = lines[len(lines)-1]
+
+	for _, line := range lines[:len(lines)-1] {
+
+		line = strings.TrimRight(line, "\r")
+		if line == "" {
+			continue
+		}
+
+		writer.logs.add(logRecord{
+			Time:    time.Now(),
+			Level:   parseLogLevel(line),
+			Message: line,
+		})
+
+	}
+
+	return len(p), nil
+
+}
+
+// parseLogLevel returns the level contained in a line of text logged through slog's TextHandler, or the Info
+// level if it doesn't contain one.
+func parseLogLevel(line string) slog.Level {
+
+	index := strings.Index(line, "level=")
+	if index < 0 {
+		return slog.LevelInfo
+	}
+
+	levelText := line[index+len("level="):]
+	if end := strings.IndexByte(levelText, ' '); end >= 0 {
+		levelText = levelText[:end]
+	}
+
+	level := slog.LevelInfo
+	if err := level.UnmarshalText([]byte(levelText)); err != nil {
+		return slog.LevelInfo
+	}
+
+	return level
+
+}
+
+// LogHandler returns a slog.Handler that forwards log records to the terminal's Log pane. If options is nil,
+// records at the Info level and above are forwarded. Attributes with the key LogNodeKey link the record to a
+// node, which can be selected from the Log pane. To keep logging to stdout as well, you can use a handler that
+// fans records out to several handlers.
+//
+// Example usage:
+//
+//	logger := slog.New(server.LogHandler(&slog.HandlerOptions{Level: slog.LevelDebug}))
+//	logger.Info("spawned enemy", tetraterm.LogNode(enemy))
+func (server *Server) LogHandler(options *slog.HandlerOptions) slog.Handler {
+	if options == nil {
+		options = &slog.HandlerOptions{}
+	}
+	return &logHandler{logs: &server.logs, options: *options}
+}
+
+type logHandler struct {
+	logs    *logBuffer
+	options slog.HandlerOptions
+	attrs   []slog.Attr
+	groups  []string
+}
+
+func (handler *logHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	minimum := slog.LevelInfo
+	if handler.options.Level != nil {
+		minimum = handler.options.Level.Level()
+	}
+	return level >= minimum
+}
+
+func (handler *logHandler) Handle(ctx context.Context, r slog.Record) error {
+
+	record := logRecord{
+		Time:    r.Time,
+		Level:   r.Level,
+		Message: r.Message,
+	}
+
+	attrTexts := []string{}
+
+	addAttr := func(groups []string, attr slog.Attr) {
+		handler.formatAttr(&record, &attrTexts, groups, attr)
+	}
+
+	for _, attr := range handler.attrs {
+		addAttr(nil, attr)
+	}
+
+	r.Attrs(func(attr slog.Attr) bool {
+		addAttr(handler.groups, attr)
+		return true
+	})
+
+	record.Attrs = strings.Join(attrTexts, " ")
+
+	handler.logs.add(record)
+
+	return nil
+
+}
+
+// formatAttr formats the attribute given as key=value text (with the key prefixed by its groups), recording
+// the node the attribute links to, if it has the key LogNodeKey.
+func (handler *logHandler) formatAttr(record *logRecord, texts *[]string, groups []string, attr slog.Attr) {
+
+	if handler.options.ReplaceAttr != nil && attr.Value.Kind() != slog.KindGroup {
+		attr = handler.options.ReplaceAttr(groups, attr)
+	}
+
+	value := attr.Value.Resolve()
+
+	if attr.Equal(slog.Attr{}) {
+		return
+	}
+
+	if value.Kind() == slog.KindGroup {
+		subgroups := groups
+		if attr.Key != "" {
+			subgroups = append(append([]string{}, groups...), attr.Key)
+		}
+		for _, child := range value.Group() {
+			handler.formatAttr(record, texts, subgroups, child)
+		}
+		return
+	}
+
+	key := strings.Join(append(append([]string{}, groups...), attr.Key), ".")
+
+	valueText := value.String()
+
+	if attr.Key == LogNodeKey {
+		if nodeID, ok := logNodeID(value.Any()); ok {
+			record.NodeID = nodeID
+			record.HasNode = true
+		}
+		if node, ok := value.Any().(tetra3d.INode); ok {
+			valueText = node.Name()
+		}
+	}
+
+	if strings.ContainsAny(valueText, " \t\"=") {
+		valueText = strconv.Quote(valueText)
+	}
+
+	*texts = append(*texts, key+"="+valueText)
+
+}
+
+// logNodeID returns the node ID given by the value of a LogNodeKey attribute.
+func logNodeID(value any) (nodeID uint32, ok bool) {
+
+	switch v := value.(type) {
+	case tetra3d.INode:
+		// A typed nil node (i.e. a nil *tetra3d.Model) isn't equal to nil, but panics when its ID is read.
+		defer func() {
+			if r := recover(); r != nil {
+				nodeID, ok = 0, false
+			}
+		}()
+		if v == nil {
+			return 0, false
+		}
+		return v.ID(), true
+	case uint32:
+		return v, true
+	case uint64:
+		// slog stores uint32 values as uint64s; larger values can't be node IDs.
+		if v <= math.MaxUint32 {
+			return uint32(v), true
+		}
+	}
+
+	return 0, false
+
+}
+
+func (handler *logHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+
+	newHandler := *handler
+	newHandler.attrs = append([]slog.Attr{}, handler.attrs...)
+
+	for _, attr := range attrs {
+		// Attributes added while in a group are nested under that group.
+		for i := len(handler.groups) - 1; i >= 0; i-- {
+			attr = slog.Attr{Key: handler.groups[i], Value: slog.GroupValue(attr)}
+		}
+		newHandler.attrs = append(newHandler.attrs, attr)
+	}
+
+	return &newHandler
+
+}
+
+func (handler *logHandler) WithGroup(name string) slog.Handler {
+
+	if name == "" {
+		return handler
+	}
+
+	newHandler := *handler
+	newHandler.groups = append(append([]string{}, handler.groups...), name)
+
+	return &newHandler
+
+}
+
+// logLevelText returns the colored name of the log level given.
+func logLevelText(level slog.Level) string {
+
+	switch {
+	case level >= slog.LevelError:
+		return "[red]" + level.String() + "[-]"
+	case level >= slog.LevelWarn:
+		return "[yellow]" + level.String() + "[-]"
+	case level >= slog.LevelInfo:
+		return "[lightblue]" + level.String() + "[-]"
+	}
+
+	return "[gray]" + level.String() + "[-]"
+
+}
+
+// initLogPane creates the Log tool pane, which displays the log records the game forwards to the terminal
+// through Server.LogWriter() and Server.LogHandler().
+func (display *Display) initLogPane() {
+
+	search := tview.NewInputField()
+	search.SetLabel("Search Log: ")
+	search.SetLabelColor(tcell.ColorLightBlue)
+	search.SetFieldBackgroundColor(tcell.ColorDarkSlateGray)
+	search.SetFieldTextColor(tcell.ColorLightBlue)
+	search.SetBackgroundColor(tcell.ColorDefault)
+
+	table := tview.NewTable()
+	table.SetSelectable(true, false)
+	table.SetBackgroundColor(tcell.ColorDefault)
+
+	status := tview.NewTextView()
+	status.SetDynamicColors(true)
+	status.SetBackgroundColor(tcell.ColorDefault)
+
+	pane := tview.NewFlex()
+	pane.SetDirection(tview.FlexRow)
+	pane.SetBorder(true)
+	pane.SetTitle("[ Log ]")
+	pane.AddItem(table, 0, 1, true)
+	pane.AddItem(search, 1, 0, false)
+	pane.AddItem(status, 1, 0, false)
+
+	display.addToolPane("log", tcell.KeyF7, pane)
+
+	records := []logRecord{}
+	levels := map[slog.Level]bool{
+		slog.LevelDebug: true,
+		slog.LevelInfo:  true,
+		slog.LevelWarn:  true,
+		slog.LevelError: true,
+	}
+	following := true
+	unseen := 0
+
+	// levelShown returns if records of the given level are shown; custom levels are grouped with the standard
+	// level below them.
+	levelShown := func(level slog.Level) bool {
+		switch {
+		case level >= slog.LevelError:
+			return levels[slog.LevelError]
+		case level >= slog.LevelWarn:
+			return levels[slog.LevelWarn]
+		case level >= slog.LevelInfo:
+			return levels[slog.LevelInfo]
+		}
+		return levels[slog.LevelDebug]
+	}
+
+	selectNode := func(nodeID uint32) {
+		display.showTreeNode(nodeID, sceneTreeAncestors(display.currentSceneTree, nodeID))
+		go display.sendRequest(newNodeSelectPacket(nodeID))
+	}
+
+	updateStatus := func() {
+
+		text := ""
+
+		for _, level := range []slog.Level{slog.LevelDebug, slog.LevelInfo, slog.LevelWarn, slog.LevelError} {
+			box := "[ ]"
+			if levels[level] {
+				box = "[x]"
+			}
+			text += tview.Escape(box) + " " + logLevelText(level) + "  "
+		}
+
+		if following {
+			text += "[green]Following[-]"
+		} else {
+			text += "[yellow]Paused"
+			if unseen > 0 {
+				text += " (" + strconv.Itoa(unseen) + " new)"
+			}
+			text += "[-]"
+		}
+
+		text += "  [gray]D/I/W/E: Toggle Levels, P: Pause, /: Search, C: Clear, Enter: Select Node[-]"
+
+		status.SetText(text)
+
+	}
+
+	refresh := func() {
+
+		table.Clear()
+
+		searchText := strings.ToLower(search.GetText())
+
+		row := 0
+
+		for _, record := range records {
+
+			if !levelShown(record.Level) {
+				continue
+			}
+
+			if searchText != "" && !strings.Contains(strings.ToLower(record.Message), searchText) && !strings.Contains(strings.ToLower(record.Attrs), searchText) {
+				continue
+			}
+
+			table.SetCell(row, 0, tview.NewTableCell(record.Time.Format("15:04:05.000")).SetTextColor(tcell.ColorGray))
+			table.SetCell(row, 1, tview.NewTableCell(logLevelText(record.Level)))
+
+			text := tview.Escape(record.Message)
+			if record.Attrs != "" {
+				text += " [gray]" + tview.Escape(record.Attrs) + "[-]"
+			}
+			table.SetCell(row, 2, tview.NewTableCell(text).SetExpansion(1).SetReference(record))
+
+			nodeCell := tview.NewTableCell("")
+			if record.HasNode {
+				nodeID := record.NodeID
+				nodeCell.SetText("[skyblue]→ Node[-]")
+				nodeCell.SetClickedFunc(func() bool {
+					selectNode(nodeID)
+					return false
+				})
+			}
+			table.SetCell(row, 3, nodeCell)
+
+			row++
+
+		}
+
+		if following && row > 0 {
+			table.Select(row-1, 0)
+			table.ScrollToEnd()
+		}
+
+		updateStatus()
+
+	}
+
+	table.SetSelectedFunc(func(row, column int) {
+		if record, ok := table.GetCell(row, 2).GetReference().(logRecord); ok && record.HasNode {
+			selectNode(record.NodeID)
+		}
+	})
+
+	table.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+
+		toggleLevel := func(level slog.Level) {
+			levels[level] = !levels[level]
+			refresh()
+		}
+
+		switch event.Rune() {
+		case 'd', 'D':
+			toggleLevel(slog.LevelDebug)
+			return nil
+		case 'i', 'I':
+			toggleLevel(slog.LevelInfo)
+			return nil
+		case 'w', 'W':
+			toggleLevel(slog.LevelWarn)
+			return nil
+		case 'e', 'E':
+			toggleLevel(slog.LevelError)
+			return nil
+		case 'p', 'P', ' ':
+			following = !following
+			if following {
+				unseen = 0
+				refresh()
+			} else {
+				updateStatus()
+			}
+			return nil
+		case 'c', 'C':
+			records = records[:0]
+			unseen = 0
+			refresh()
+			return nil
+		case '/':
+			display.App.SetFocus(search)
+			return nil
+		}
+
+		return event
+
+	})
+
+	search.SetChangedFunc(func(text string) {
+		refresh()
+	})
+
+	search.SetDoneFunc(func(key tcell.Key) {
+		display.App.SetFocus(table)
+	})
+
+	updateStatus()
+
+	go func() {
+
+		lastSeq := uint64(0)
+		session := int64(0)
+
+		for {
+
+			time.Sleep(time.Millisecond * 250)
+
+			if !display.running.Load() {
+				return
+			}
+
+			if !display.toolPaneVisible("log") {
+				continue
+			}
+
+			resp, err := display.sendRequest(newLogsPacket(lastSeq))
+			if err != nil {
+				continue
+			}
+
+			packet := resp.(*logsPacket)
+
+			// If the game restarted, its sequence numbers start over, so the previous session's records are
+			// cleared and the new session's are requested from the start.
+			if packet.Session != session {
+
+				restarted := session != 0
+				session = packet.Session
+
+				if restarted {
+					lastSeq = 0
+					display.App.QueueUpdate(func() {
+						records = records[:0]
+						unseen = 0
+						refresh()
+					})
+					continue
+				}
+
+			}
+
+			if len(packet.Records) == 0 {
+				continue
+			}
+
+			lastSeq = packet.Records[len(packet.Records)-1].Seq
+
+			display.App.QueueUpdate(func() {
+
+				records = append(records, packet.Records...)
+				if len(records) > logPaneSize {
+					records = append(records[:0], records[len(records)-logPaneSize:]...)
+				}
+
+				if following {
+					refresh()
+				} else {
+					unseen += len(packet.Records)
+					updateStatus()
+				}
+
+			})
+
+		}
+
+	}()
+
+}
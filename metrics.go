@@ -0,0 +1,270 @@
+package tetraterm
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/rivo/tview"
+)
+
+const (
+	// How often metrics are sampled for their history graphs.
+	metricSampleInterval = time.Millisecond * 250
+	// How many samples of history are kept for each metric.
+	metricHistoryLength = 40
+)
+
+// MetricOptions controls how a metric is displayed in the terminal.
+type MetricOptions struct {
+	Graph bool // Whether a graph of the metric's recent history should be displayed alongside its value
+
+	// If WarnAbove is set, the metric's value is displayed in red when it's higher than WarnAbove (i.e.
+	// WarnAbove: tetraterm.MetricThreshold(0) warns as soon as the metric is positive). For counters, this
+	// applies to their rate per second.
+	WarnAbove *float64
+	// If WarnBelow is set, the metric's value is displayed in red when it's lower than WarnBelow.
+	// For counters, this applies to their rate per second.
+	WarnBelow *float64
+}
+
+// MetricThreshold returns a pointer to the value given, for use as MetricOptions.WarnAbove or WarnBelow.
+func MetricThreshold(value float64) *float64 {
+	return &value
+}
+
+// Counter represents a custom metric that counts how often something happens, like pathfinding queries or
+// enemies spawned. The terminal displays both its total and its rate per second. Create a Counter using
+// Server.Counter().
+type Counter struct {
+	metrics *customMetrics
+	metric  *customMetric
+}
+
+// Add adds the value given to the Counter.
+func (counter *Counter) Add(value float64) {
+	counter.metrics.mutex.Lock()
+	counter.metric.value += value
+	counter.metrics.mutex.Unlock()
+}
+
+// Inc increments the Counter by 1.
+func (counter *Counter) Inc() {
+	counter.Add(1)
+}
+
+type customMetric struct {
+	name        string
+	counter     bool
+	value       float64
+	rate        float64
+	sampleValue float64
+	history     []float64
+	options     MetricOptions
+}
+
+// metricKey identifies a custom metric; counters and gauges are kept apart, so a counter and a gauge that share
+// a name are separate metrics.
+type metricKey struct {
+	name    string
+	counter bool
+}
+
+// customMetrics holds the custom metrics created by game code.
+type customMetrics struct {
+	mutex      sync.Mutex
+	metrics    map[metricKey]*customMetric
+	counters   map[string]*Counter
+	lastSample time.Time
+}
+
+func (metrics *customMetrics) get(name string, counter bool) *customMetric {
+
+	if metrics.metrics == nil {
+		metrics.metrics = map[metricKey]*customMetric{}
+	}
+
+	key := metricKey{name: name, counter: counter}
+
+	metric, exists := metrics.metrics[key]
+	if !exists {
+		metric = &customMetric{name: name, counter: counter}
+		metrics.metrics[key] = metric
+	}
+
+	return metric
+
+}
+
+// sample records the current value of each metric (or its rate, in the case of counters) into its history,
+// if enough time has passed since the last sample.
+func (metrics *customMetrics) sample() {
+
+	metrics.mutex.Lock()
+	defer metrics.mutex.Unlock()
+
+	now := time.Now()
+	elapsed := now.Sub(metrics.lastSample)
+
+	if elapsed < metricSampleInterval {
+		return
+	}
+
+	firstSample := metrics.lastSample.IsZero()
+	metrics.lastSample = now
+
+	for _, metric := range metrics.metrics {
+
+		sample := metric.value
+
+		if metric.counter {
+			if !firstSample {
+				metric.rate = (metric.value - metric.sampleValue) / elapsed.Seconds()
+			}
+			metric.sampleValue = metric.value
+			sample = metric.rate
+		}
+
+		// Values that aren't finite (like a gauge set to total / 0) can't be graphed, so they're left out.
+		if math.IsNaN(sample) || math.IsInf(sample, 0) {
+			continue
+		}
+
+		metric.history = append(metric.history, sample)
+		if len(metric.history) > metricHistoryLength {
+			metric.history = metric.history[len(metric.history)-metricHistoryLength:]
+		}
+
+	}
+
+}
+
+// info returns the current state of each metric, sorted by name.
+func (metrics *customMetrics) info() []metricInfo {
+
+	metrics.mutex.Lock()
+	defer metrics.mutex.Unlock()
+
+	out := make([]metricInfo, 0, len(metrics.metrics))
+
+	for _, metric := range metrics.metrics {
+		out = append(out, metricInfo{
+			Name:    metric.name,
+			Counter: metric.counter,
+			Value:   metric.value,
+			Rate:    metric.rate,
+			History: append([]float64{}, metric.history...),
+			Options: metric.options,
+		})
+	}
+
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Name != out[j].Name {
+			return out[i].Name < out[j].Name
+		}
+		return out[i].Counter
+	})
+
+	return out
+
+}
+
+// Counter returns the Counter metric with the given name, creating it if it doesn't exist already.
+// Counters are displayed in the Game Status area of the terminal with their total and rate per second.
+//
+// Example usage:
+//
+//	server.Counter("pathfinding queries").Inc()
+func (server *Server) Counter(name string) *Counter {
+
+	server.metrics.mutex.Lock()
+	defer server.metrics.mutex.Unlock()
+
+	// Counters are cached, so calling Counter() every frame doesn't allocate.
+	if counter, exists := server.metrics.counters[name]; exists {
+		return counter
+	}
+
+	if server.metrics.counters == nil {
+		server.metrics.counters = map[string]*Counter{}
+	}
+
+	counter := &Counter{
+		metrics: &server.metrics,
+		metric:  server.metrics.get(name, true),
+	}
+
+	server.metrics.counters[name] = counter
+
+	return counter
+
+}
+
+// Gauge sets the value of the named gauge metric, creating it if it doesn't exist already. Gauges represent
+// a value at a point in time, like the number of active enemies or bullets alive, and are displayed in the
+// Game Status area of the terminal.
+func (server *Server) Gauge(name string, value float64) {
+
+	server.metrics.mutex.Lock()
+	defer server.metrics.mutex.Unlock()
+
+	server.metrics.get(name, false).value = value
+
+}
+
+// SetMetricOptions sets the options used to display the named metric in the terminal, like whether it should
+// be graphed or should turn red when it passes a threshold. The metric should already have been created through
+// Server.Counter() or Server.Gauge(); if both a counter and a gauge have the name, both use the options.
+func (server *Server) SetMetricOptions(name string, options MetricOptions) {
+
+	server.metrics.mutex.Lock()
+	defer server.metrics.mutex.Unlock()
+
+	for _, counter := range []bool{true, false} {
+		if metric, exists := server.metrics.metrics[metricKey{name: name, counter: counter}]; exists {
+			metric.options = options
+		}
+	}
+
+}
+
+// formatMetrics returns the custom metrics given as text for the Game Status area.
+func formatMetrics(metrics []metricInfo) string {
+
+	text := ""
+
+	for _, metric := range metrics {
+
+		value := metric.Value
+		valueText := formatMetricValue(metric.Value)
+
+		if metric.Counter {
+			value = metric.Rate
+			valueText += " (" + formatMetricValue(metric.Rate) + "/s)"
+		}
+
+		if (metric.Options.WarnAbove != nil && value > *metric.Options.WarnAbove) || (metric.Options.WarnBelow != nil && value < *metric.Options.WarnBelow) {
+			valueText = "[red::b]" + valueText + "[-::-]"
+		}
+
+		text += fmt.Sprintf("\n%s: %s", tview.Escape(metric.Name), valueText)
+
+		if metric.Options.Graph && len(metric.History) > 0 {
+			text += " [green]" + sparkline(metric.History, metricHistoryLength) + "[-]"
+		}
+
+	}
+
+	return text
+
+}
+
+func formatMetricValue(value float64) string {
+	if value == float64(int64(value)) {
+		return strconv.FormatInt(int64(value), 10)
+	}
+	return strconv.FormatFloat(value, 'f', 2, 64)
+}
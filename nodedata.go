@@ -0,0 +1,176 @@
+package tetraterm
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/solarlune/tetra3d"
+)
+
+// nodeDataSnapshot holds the serialized game data (set through INode.SetData()) of the selected node, as well
+// as the fields contributed for it by inspectors registered through Server.RegisterInspector().
+type nodeDataSnapshot struct {
+	mutex      sync.Mutex
+	nodeID     uint32
+	hasData    bool
+	data       valueNode
+	sections   []valueNode
+	inspectors []Inspector
+	requested  time.Time
+}
+
+// update serializes the selected node's data and inspector fields, but only if the terminal has asked for
+// them recently; this is called on the game thread from Server.Update(), as game data may be modified by the
+// game at any time.
+func (snapshot *nodeDataSnapshot) update(node tetra3d.INode) {
+
+	snapshot.mutex.Lock()
+	requested := snapshot.requested
+	inspectors := append([]Inspector{}, snapshot.inspectors...)
+	snapshot.mutex.Unlock()
+
+	if node == nil || time.Since(requested) > watchRequestTimeout {
+		return
+	}
+
+	hasData := node.Data() != nil
+	data := valueNode{}
+	if hasData {
+		data = newValueTree("Data", node.Data())
+	}
+
+	sections := newInspectorSections(inspectNode(inspectors, node))
+
+	snapshot.mutex.Lock()
+	snapshot.nodeID = node.ID()
+	snapshot.hasData = hasData
+	snapshot.data = data
+	snapshot.sections = sections
+	snapshot.mutex.Unlock()
+
+}
+
+// latest returns the most recently serialized data and inspector sections for the node with the given ID, and
+// marks them as having been requested.
+func (snapshot *nodeDataSnapshot) latest(nodeID uint32) (data valueNode, hasData bool, sections []valueNode) {
+
+	snapshot.mutex.Lock()
+	defer snapshot.mutex.Unlock()
+
+	snapshot.requested = time.Now()
+
+	if snapshot.nodeID != nodeID {
+		return valueNode{}, false, nil
+	}
+
+	return snapshot.data, snapshot.hasData, snapshot.sections
+
+}
+
+// setSelectedNodeData sets the value at the path given in the selected node's data.
+func (server *Server) setSelectedNodeData(nodeID uint32, path []string, text string) error {
+
+	return server.runOnGameThreadAndWait(func() error {
+
+		if server.selectedNode == nil || server.selectedNode.ID() != nodeID {
+			return errors.New("the node is no longer selected")
+		}
+
+		if server.selectedNode.Data() == nil {
+			return errors.New("the node has no data")
+		}
+
+		return setValueAtPath(reflect.ValueOf(server.selectedNode.Data()), path, text)
+
+	})
+
+}
+
+// initNodeDataView creates the view of the selected node's game data and inspector fields, displayed underneath
+// the Node Properties.
+func (display *Display) initNodeDataView() {
+
+	display.nodeDataView = newValueTreeView()
+	display.nodeDataView.SetBorder(true)
+	display.nodeDataView.SetTitle("[ Node Data ]")
+	// The top-level data value starts out expanded.
+	display.nodeDataView.expanded["\x00Data"] = true
+
+	display.nodeDataView.EditFunc = func(root valueNode, path []string, value valueNode) {
+
+		nodeID := display.selectedNodeID.Load()
+
+		if root.Type == inspectorSectionType {
+
+			display.promptValue("Edit "+root.Name+"."+value.Name, value.Value, func(text string) error {
+
+				res, err := display.sendRequest(newNodeInspectorSetPacket(nodeID, root.Name, value.Name, text))
+				if err != nil {
+					return err
+				}
+
+				if errText := res.(*nodeInspectorSetPacket).Error; errText != "" {
+					return errors.New(errText)
+				}
+
+				return nil
+
+			})
+
+			return
+
+		}
+
+		display.promptValue("Edit Data."+strings.Join(path, "."), value.Value, func(text string) error {
+
+			res, err := display.sendRequest(newNodeDataSetPacket(nodeID, path, text))
+			if err != nil {
+				return err
+			}
+
+			if errText := res.(*nodeDataSetPacket).Error; errText != "" {
+				return errors.New(errText)
+			}
+
+			return nil
+
+		})
+
+	}
+
+	display.nodeDataView.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		if event.Key() == tcell.KeyEscape {
+			display.App.SetFocus(display.TreeView)
+			return nil
+		}
+		return event
+	})
+
+}
+
+// setNodeData updates the Node Data view with the selected node's game data and inspector sections, hiding
+// the view if the node has neither.
+func (display *Display) setNodeData(data valueNode, hasData bool, sections []valueNode) {
+
+	values := []valueNode{}
+	if hasData {
+		values = append(values, data)
+	}
+	values = append(values, sections...)
+
+	if len(values) > 0 {
+		display.nodeDataView.SetValues(values)
+		display.nodeProperties.ResizeItem(display.nodeDataView, 0, 1)
+	} else {
+		display.nodeDataView.SetValues(nil)
+		display.nodeProperties.ResizeItem(display.nodeDataView, 0, 0)
+		if display.nodeDataView.HasFocus() {
+			display.App.SetFocus(display.TreeView)
+		}
+	}
+
+}
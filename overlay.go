@@ -0,0 +1,347 @@
+package tetraterm
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/rivo/tview"
+	"github.com/solarlune/tetra3d"
+	"github.com/solarlune/tetra3d/colors"
+)
+
+// OverlaySettings controls how the Server's debug overlays (toggled with 1, 2, and 3 in the terminal) are drawn.
+type OverlaySettings struct {
+	HierarchyColor tetra3d.Color // The color of the node centers and parent lines drawn by the hierarchy overlay
+	SelectedColor  tetra3d.Color // The color of the selected node's name
+	NameColor      tetra3d.Color // The color of other nodes' names, if DrawAllNames is true
+	WireframeColor tetra3d.Color // The color of the wireframe overlay
+
+	// The settings used to draw the bounds overlay, including whether broadphase cells and the AABBs surrounding
+	// BoundingTriangles are drawn.
+	Bounds tetra3d.DrawDebugBoundsColoredSettings
+
+	// If the bounds and wireframe overlays (and axes, if DrawAllAxes is true) are limited to the selected node and
+	// its children, rather than the whole scene.
+	SelectedSubtreeOnly bool
+
+	// If the hierarchy overlay draws the names of all visible nodes, rather than just the selected node.
+	DrawAllNames bool
+	// If set, only the names of nodes of this type (i.e. tetra3d.NodeTypeModel or tetra3d.NodeTypeLight) are
+	// drawn; as with NodeType.Is(), more general types include more specific ones.
+	NameTypeFilter tetra3d.NodeType
+	// The furthest distance from the camera that names are drawn at; if 0, the camera's far plane is used.
+	NameMaxDistance float32
+
+	DrawAxes    bool    // If local axes gizmos are drawn for the selected node
+	DrawAllAxes bool    // If local axes gizmos are drawn for all nodes (or just the selected subtree)
+	AxesLength  float32 // The length of the axes gizmos' lines in world units
+
+	LightGizmoColor  tetra3d.Color // The color of light gizmos (toggled with 5 in the terminal)
+	CameraGizmoColor tetra3d.Color // The color of camera frustum gizmos
+	PathGizmoColor   tetra3d.Color // The color of path gizmos
+	// The length of directional and cube light arrows in world units; camera frustums are drawn out to this
+	// distance as well, if it's closer than their far plane.
+	GizmoLength float32
+
+	FlashSelected   bool          // If the wireframes of the models under the selected node flash in HighlightColor
+	OutlineSelected bool          // If the selected node's wireframe is drawn thickly in HighlightColor
+	PointToSelected bool          // If an arrow at the edge of the screen points toward the selected node when it's off-screen
+	HighlightColor  tetra3d.Color // The color used to highlight the selected node
+}
+
+// NewDefaultOverlaySettings returns a new OverlaySettings object filled out with the default settings.
+func NewDefaultOverlaySettings() OverlaySettings {
+
+	bounds := tetra3d.DefaultDrawDebugBoundsSettings()
+	bounds.RenderBroadphases = false
+	bounds.RenderTrianglesAABB = false
+
+	return OverlaySettings{
+		HierarchyColor: colors.White(),
+		SelectedColor:  colors.White(),
+		NameColor:      colors.Gray(),
+		WireframeColor: colors.LightGray(),
+		Bounds:         bounds,
+		AxesLength:     1,
+
+		LightGizmoColor:  colors.Yellow(),
+		CameraGizmoColor: colors.SkyBlue(),
+		PathGizmoColor:   colors.Orange(),
+		GizmoLength:      2,
+
+		HighlightColor: colors.Yellow(),
+	}
+
+}
+
+// showsName returns if the name of the node given should be drawn by the hierarchy overlay.
+func (settings OverlaySettings) showsName(node tetra3d.INode, camera *tetra3d.Camera) bool {
+
+	if !node.IsVisible() {
+		return false
+	}
+
+	if settings.NameTypeFilter != "" && !node.Type().Is(settings.NameTypeFilter) {
+		return false
+	}
+
+	maxDistance := settings.NameMaxDistance
+	if maxDistance <= 0 {
+		maxDistance = camera.Far()
+	}
+
+	return camera.WorldPosition().DistanceTo(node.WorldPosition()) <= maxDistance
+
+}
+
+// drawDebugAxes draws the local axes of the node given, with X in red, Y in green, and Z in blue.
+func drawDebugAxes(screen *ebiten.Image, camera *tetra3d.Camera, node tetra3d.INode, length float32) {
+
+	if length <= 0 {
+		length = 1
+	}
+
+	pos := node.WorldPosition()
+	rotation := node.WorldRotation()
+
+	drawDebugLine3D(screen, camera, pos, pos.Add(rotation.Right().Scale(length)), colors.Red(), 2)
+	drawDebugLine3D(screen, camera, pos, pos.Add(rotation.Up().Scale(length)), colors.Green(), 2)
+	drawDebugLine3D(screen, camera, pos, pos.Add(rotation.Forward().Scale(length)), colors.SkyBlue(), 2)
+
+}
+
+// overlayColors are the colors that can be chosen for overlays from the Overlay Options page.
+var overlayColors = []struct {
+	name  string
+	color tetra3d.Color
+}{
+	{"White", colors.White()},
+	{"Light Gray", colors.LightGray()},
+	{"Gray", colors.Gray()},
+	{"Dark Gray", colors.DarkGray()},
+	{"Black", colors.Black()},
+	{"Red", colors.Red()},
+	{"Pale Red", colors.PaleRed()},
+	{"Orange", colors.Orange()},
+	{"Yellow", colors.Yellow()},
+	{"Green", colors.Green()},
+	{"Turquoise", colors.Turquoise()},
+	{"Sky Blue", colors.SkyBlue()},
+	{"Blue", colors.Blue()},
+	{"Purple", colors.Purple()},
+	{"Pink", colors.Pink()},
+}
+
+// overlayNodeTypes are the node types that names can be filtered by from the Overlay Options page.
+var overlayNodeTypes = []struct {
+	name     string
+	nodeType tetra3d.NodeType
+}{
+	{"All", ""},
+	{"Models", tetra3d.NodeTypeModel},
+	{"Cameras", tetra3d.NodeTypeCamera},
+	{"Lights", tetra3d.NodeTypeLight},
+	{"Paths", tetra3d.NodeTypePath},
+	{"Grids", tetra3d.NodeTypeGrid},
+	{"Bounding Objects", tetra3d.NodeTypeBoundingObject},
+}
+
+// initOverlayOptionsPage creates the Overlay Options page, which edits the Server's OverlaySettings. The page's
+// form is filled out when it's shown, as the settings are fetched from the game then.
+func (display *Display) initOverlayOptionsPage() {
+
+	form := tview.NewForm()
+	form.SetBackgroundColor(tcell.ColorDefault)
+	form.SetFieldBackgroundColor(tcell.ColorDarkSlateGray)
+	form.SetFieldTextColor(tcell.ColorLightBlue)
+	form.SetLabelColor(tcell.ColorLightBlue)
+	form.SetItemPadding(0)
+
+	form.SetCancelFunc(func() {
+		display.Root.HidePage("overlay options")
+	})
+
+	status := tview.NewTextView()
+	status.SetDynamicColors(true)
+	status.SetBackgroundColor(tcell.ColorDefault)
+
+	layout := tview.NewFlex()
+	layout.SetDirection(tview.FlexRow)
+	layout.SetBorder(true)
+	layout.SetTitle("[ Debug Overlay Options ]")
+	layout.AddItem(form, 0, 1, true)
+	layout.AddItem(status, 1, 0, false)
+
+	display.Root.AddPage("overlay options", newCenteredPrimitive(layout, 72, 34), true, false)
+
+	display.overlayOptionsForm = form
+	display.overlayOptionsStatus = status
+
+}
+
+// showOverlayOptions fetches the game's overlay settings and shows the Overlay Options page to edit them.
+func (display *Display) showOverlayOptions() {
+
+	go func() {
+
+		res, err := display.sendRequest(newOverlaySettingsPacket())
+
+		display.App.QueueUpdate(func() {
+
+			if err != nil {
+				display.overlayOptionsForm.Clear(true)
+				display.overlayOptionsForm.AddButton("Close", func() { display.Root.HidePage("overlay options") })
+				display.overlayOptionsStatus.SetText("[red]Couldn't fetch the overlay settings: " + tview.Escape(err.Error()) + "[-]")
+			} else {
+				display.buildOverlayOptionsForm(res.(*overlaySettingsPacket).Settings)
+			}
+
+			display.Root.ShowPage("overlay options")
+
+		})
+
+	}()
+
+}
+
+// buildOverlayOptionsForm fills out the Overlay Options form with the settings given. Changes are sent to the game
+// as they're made.
+func (display *Display) buildOverlayOptionsForm(settings OverlaySettings) {
+
+	form := display.overlayOptionsForm
+	status := display.overlayOptionsStatus
+
+	form.Clear(true)
+
+	status.SetText("[gray]Changes apply immediately. Esc: Close[-]")
+
+	// Dropdowns call their selected functions when they're created, so changes aren't sent until the form's built.
+	building := true
+
+	apply := func() {
+
+		if building {
+			return
+		}
+
+		packet := newOverlaySettingsPacket()
+		packet.Set = true
+		packet.Settings = settings
+
+		go func() {
+			_, err := display.sendRequest(packet)
+			display.App.QueueUpdate(func() {
+				if err != nil {
+					status.SetText("[red]Couldn't apply the settings: " + tview.Escape(err.Error()) + "[-]")
+				} else {
+					status.SetText("[green]Applied.[-] [gray]Esc: Close[-]")
+				}
+			})
+		}()
+
+	}
+
+	addColor := func(label string, target *tetra3d.Color) {
+
+		options := []string{}
+		current := -1
+
+		for i, c := range overlayColors {
+			options = append(options, c.name)
+			if c.color.R == target.R && c.color.G == target.G && c.color.B == target.B {
+				current = i
+			}
+		}
+
+		// Colors set from the game that aren't in the list are kept as a custom option.
+		custom := *target
+		if current < 0 {
+			options = append(options, fmt.Sprintf("Custom (%.2f, %.2f, %.2f)", custom.R, custom.G, custom.B))
+			current = len(options) - 1
+		}
+
+		form.AddDropDown(label, options, current, func(option string, index int) {
+			if index < len(overlayColors) {
+				// The color's alpha is kept, as the bounds colors are translucent.
+				alpha := target.A
+				*target = overlayColors[index].color
+				target.A = alpha
+			} else {
+				*target = custom
+			}
+			apply()
+		})
+
+	}
+
+	addCheckbox := func(label string, target *bool) {
+		form.AddCheckbox(label, *target, func(checked bool) {
+			*target = checked
+			apply()
+		})
+	}
+
+	addNumber := func(label string, target *float32) {
+		form.AddInputField(label, strconv.FormatFloat(float64(*target), 'f', -1, 32), 8, tview.InputFieldFloat, func(text string) {
+			if value, err := strconv.ParseFloat(text, 32); err == nil && value >= 0 {
+				*target = float32(value)
+				apply()
+			}
+		})
+	}
+
+	addColor("Hierarchy Color: ", &settings.HierarchyColor)
+	addColor("Selected Name Color: ", &settings.SelectedColor)
+	addColor("Other Names Color: ", &settings.NameColor)
+	addColor("Wireframe Color: ", &settings.WireframeColor)
+	addColor("AABB Color: ", &settings.Bounds.AABBColor)
+	addColor("Sphere Color: ", &settings.Bounds.SphereColor)
+	addColor("Capsule Color: ", &settings.Bounds.CapsuleColor)
+	addColor("Triangles Color: ", &settings.Bounds.TrianglesColor)
+	addCheckbox("Draw Triangle AABBs: ", &settings.Bounds.RenderTrianglesAABB)
+	addColor("Triangle AABB Color: ", &settings.Bounds.TrianglesAABBColor)
+	addCheckbox("Draw Broadphases: ", &settings.Bounds.RenderBroadphases)
+	addColor("Broadphase Color: ", &settings.Bounds.BroadphaseColor)
+	addCheckbox("Selected Subtree Only: ", &settings.SelectedSubtreeOnly)
+	addCheckbox("Draw All Names: ", &settings.DrawAllNames)
+
+	typeOptions := []string{}
+	currentType := 0
+	for i, t := range overlayNodeTypes {
+		typeOptions = append(typeOptions, t.name)
+		if t.nodeType == settings.NameTypeFilter {
+			currentType = i
+		}
+	}
+
+	form.AddDropDown("Names Of: ", typeOptions, currentType, func(option string, index int) {
+		settings.NameTypeFilter = overlayNodeTypes[index].nodeType
+		apply()
+	})
+
+	addNumber("Name Max Distance (0 = Far): ", &settings.NameMaxDistance)
+	addCheckbox("Draw Selected Node's Axes: ", &settings.DrawAxes)
+	addCheckbox("Draw All Nodes' Axes: ", &settings.DrawAllAxes)
+	addNumber("Axes Length: ", &settings.AxesLength)
+	addColor("Light Gizmo Color: ", &settings.LightGizmoColor)
+	addColor("Camera Gizmo Color: ", &settings.CameraGizmoColor)
+	addColor("Path Gizmo Color: ", &settings.PathGizmoColor)
+	addNumber("Gizmo Length: ", &settings.GizmoLength)
+	addCheckbox("Flash Selected Node: ", &settings.FlashSelected)
+	addCheckbox("Outline Selected Node: ", &settings.OutlineSelected)
+	addCheckbox("Point to Off-Screen Selected: ", &settings.PointToSelected)
+	addColor("Highlight Color: ", &settings.HighlightColor)
+
+	form.AddButton("Reset to Defaults", func() {
+		settings = NewDefaultOverlaySettings()
+		apply()
+		display.buildOverlayOptionsForm(settings)
+	})
+
+	form.AddButton("Close", func() { display.Root.HidePage("overlay options") })
+
+	building = false
+
+}
@@ -2,7 +2,9 @@ package tetraterm
 
 import (
 	"strconv"
+	"time"
 
+	"github.com/hajimehoshi/ebiten/v2"
 	p2p "github.com/leprosus/golang-p2p"
 	"github.com/solarlune/tetra3d"
 )
@@ -23,6 +25,34 @@ const (
 	ptToggleDebugDrawHierarchy = "ToggleDebugDrawHierarchy"
 	ptToggleDebugDrawWireframe = "ToggleDebugDrawWireframe"
 	ptToggleDebugDrawBounds    = "ToggleDebugDrawBounds"
+	ptToggleDebugDrawShapes    = "ToggleDebugDrawShapes"
+	ptToggleDebugDrawGizmos    = "ToggleDebugDrawGizmos"
+	ptProfileStart             = "ProfileStart"
+	ptProfileFetch             = "ProfileFetch"
+	ptTimingScopes             = "TimingScopes"
+	ptWatches                  = "Watches"
+	ptTweaks                   = "Tweaks"
+	ptTweakSet                 = "TweakSet"
+	ptNodeDataSet              = "NodeDataSet"
+	ptNodeInspectorSet         = "NodeInspectorSet"
+	ptActions                  = "Actions"
+	ptActionRun                = "ActionRun"
+	ptCommands                 = "Commands"
+	ptCommandRun               = "CommandRun"
+	ptNodeSetVisible           = "NodeSetVisible"
+	ptLogs                     = "Logs"
+	ptCrash                    = "Crash"
+	ptCrashAck                 = "CrashAck"
+	ptCameraState              = "CameraState"
+	ptTimeControl              = "TimeControl"
+	ptBreakpoints              = "Breakpoints"
+	ptBreakpointEdit           = "BreakpointEdit"
+	ptInput                    = "Input"
+	ptDebugDraw                = "DebugDraw"
+	ptOverlaySettings          = "OverlaySettings"
+	ptSectors                  = "Sectors"
+	ptWorld                    = "World"
+	ptLights                   = "Lights"
 )
 
 type iPacket interface {
@@ -116,6 +146,7 @@ func (packet *nodeRotatePacket) DataType() string {
 
 type nodeSelectPacket struct {
 	NodeID uint32
+	Error  string // Set if the node wasn't found, in which case the selection isn't changed
 }
 
 func newNodeSelectPacket(nodeID uint32) *nodeSelectPacket {
@@ -291,12 +322,15 @@ func (mat matrix3) String() string {
 }
 
 type nodeInfoPacket struct {
-	ID       uint32
-	Position tetra3d.Vector3
-	Scale    tetra3d.Vector3
-	Rotation matrix3
-	Visible  bool
-	Type     tetra3d.NodeType
+	ID         uint32
+	Position   tetra3d.Vector3
+	Scale      tetra3d.Vector3
+	Rotation   matrix3
+	Visible    bool
+	Type       tetra3d.NodeType
+	HasData    bool
+	Data       valueNode
+	Inspectors []valueNode
 }
 
 func newNodeInfoPacket() *nodeInfoPacket {
@@ -361,6 +395,10 @@ func (packet *nodeMoveInTreePacket) DataType() string {
 type nodeCreatePacket struct {
 	NodeToCreate string
 
+	// The ID of the node to parent the clone to, if HasParent is set; otherwise, the clone is parented to the scene root.
+	Parent    uint32
+	HasParent bool
+
 	ViableNodes []string
 
 	NewSelectedNode uint32
@@ -390,12 +428,27 @@ func (packet *nodeCreatePacket) DataType() string {
 
 /////
 
+type metricInfo struct {
+	Name    string
+	Counter bool
+	Value   float64
+	Rate    float64
+	History []float64
+	Options MetricOptions
+}
+
 type gameInfoPacket struct {
 	FPS, TPS        float32
 	DebugInfo       tetra3d.DebugInfo
 	SectorRendering bool
 	Sector          string
 	SectorNeighbors []string
+	Metrics         []metricInfo
+	Paused          bool
+	Steps           int
+	TimeScale       float64
+	PickedNode      uint32 // The node selected by Ctrl+clicking its gizmo in the game window, if HasPickedNode is true
+	HasPickedNode   bool
 }
 
 func newGameInfoPacket() *gameInfoPacket {
@@ -499,3 +552,868 @@ func (packet *toggleDebugDrawBounds) Decode(req p2p.Data) error {
 func (packet *toggleDebugDrawBounds) DataType() string {
 	return ptToggleDebugDrawBounds
 }
+
+/////
+
+type toggleDebugDrawShapes struct {
+	DebugDrawOn bool
+}
+
+func newToggleDebugDrawShapes() *toggleDebugDrawShapes {
+	return &toggleDebugDrawShapes{}
+}
+
+func (packet *toggleDebugDrawShapes) Encode() p2p.Data {
+	data := p2p.Data{}
+	err := data.SetGob(packet)
+	if err != nil {
+		panic(err)
+	}
+	return data
+}
+
+func (packet *toggleDebugDrawShapes) Decode(req p2p.Data) error {
+	return req.GetGob(&packet)
+}
+
+func (packet *toggleDebugDrawShapes) DataType() string {
+	return ptToggleDebugDrawShapes
+}
+
+/////
+
+type toggleDebugDrawGizmos struct {
+	DebugDrawOn bool
+}
+
+func newToggleDebugDrawGizmos() *toggleDebugDrawGizmos {
+	return &toggleDebugDrawGizmos{}
+}
+
+func (packet *toggleDebugDrawGizmos) Encode() p2p.Data {
+	data := p2p.Data{}
+	err := data.SetGob(packet)
+	if err != nil {
+		panic(err)
+	}
+	return data
+}
+
+func (packet *toggleDebugDrawGizmos) Decode(req p2p.Data) error {
+	return req.GetGob(&packet)
+}
+
+func (packet *toggleDebugDrawGizmos) DataType() string {
+	return ptToggleDebugDrawGizmos
+}
+
+/////
+
+const (
+	profileKindCPU = iota
+	profileKindHeap
+	profileKindTrace
+)
+
+type profileStartPacket struct {
+	Kind     int
+	Duration time.Duration
+	Error    string
+}
+
+func newProfileStartPacket(kind int, duration time.Duration) *profileStartPacket {
+	return &profileStartPacket{Kind: kind, Duration: duration}
+}
+
+func (packet *profileStartPacket) Encode() p2p.Data {
+	data := p2p.Data{}
+	err := data.SetGob(packet)
+	if err != nil {
+		panic(err)
+	}
+	return data
+}
+
+func (packet *profileStartPacket) Decode(req p2p.Data) error {
+	return req.GetGob(&packet)
+}
+
+func (packet *profileStartPacket) DataType() string {
+	return ptProfileStart
+}
+
+/////
+
+type profileFetchPacket struct {
+	Offset    int
+	Kind      int
+	Capturing bool
+	Chunk     []byte
+	Total     int
+	Error     string
+}
+
+func newProfileFetchPacket(offset int) *profileFetchPacket {
+	return &profileFetchPacket{Offset: offset}
+}
+
+func (packet *profileFetchPacket) Encode() p2p.Data {
+	data := p2p.Data{}
+	err := data.SetGob(packet)
+	if err != nil {
+		panic(err)
+	}
+	return data
+}
+
+func (packet *profileFetchPacket) Decode(req p2p.Data) error {
+	return req.GetGob(&packet)
+}
+
+func (packet *profileFetchPacket) DataType() string {
+	return ptProfileFetch
+}
+
+/////
+
+type timingScopeInfo struct {
+	Path    string
+	Last    time.Duration
+	Average time.Duration
+	Max     time.Duration
+	History []time.Duration
+}
+
+type timingScopesPacket struct {
+	Scopes []timingScopeInfo
+}
+
+func newTimingScopesPacket() *timingScopesPacket {
+	return &timingScopesPacket{}
+}
+
+func (packet *timingScopesPacket) Encode() p2p.Data {
+	data := p2p.Data{}
+	err := data.SetGob(packet)
+	if err != nil {
+		panic(err)
+	}
+	return data
+}
+
+func (packet *timingScopesPacket) Decode(req p2p.Data) error {
+	return req.GetGob(&packet)
+}
+
+func (packet *timingScopesPacket) DataType() string {
+	return ptTimingScopes
+}
+
+/////
+
+type watchesPacket struct {
+	Watches []valueNode
+}
+
+func newWatchesPacket() *watchesPacket {
+	return &watchesPacket{}
+}
+
+func (packet *watchesPacket) Encode() p2p.Data {
+	data := p2p.Data{}
+	err := data.SetGob(packet)
+	if err != nil {
+		panic(err)
+	}
+	return data
+}
+
+func (packet *watchesPacket) Decode(req p2p.Data) error {
+	return req.GetGob(&packet)
+}
+
+func (packet *watchesPacket) DataType() string {
+	return ptWatches
+}
+
+/////
+
+const (
+	tweakKindFloat = iota
+	tweakKindInt
+	tweakKindBool
+	tweakKindString
+	tweakKindEnum
+)
+
+type tweakInfo struct {
+	Name    string
+	Kind    int
+	Value   string
+	Options TweakOptions
+}
+
+type tweaksPacket struct {
+	Tweaks []tweakInfo
+}
+
+func newTweaksPacket() *tweaksPacket {
+	return &tweaksPacket{}
+}
+
+func (packet *tweaksPacket) Encode() p2p.Data {
+	data := p2p.Data{}
+	err := data.SetGob(packet)
+	if err != nil {
+		panic(err)
+	}
+	return data
+}
+
+func (packet *tweaksPacket) Decode(req p2p.Data) error {
+	return req.GetGob(&packet)
+}
+
+func (packet *tweaksPacket) DataType() string {
+	return ptTweaks
+}
+
+/////
+
+type tweakSetPacket struct {
+	Name  string
+	Value string
+	Error string
+}
+
+func newTweakSetPacket(name, value string) *tweakSetPacket {
+	return &tweakSetPacket{Name: name, Value: value}
+}
+
+func (packet *tweakSetPacket) Encode() p2p.Data {
+	data := p2p.Data{}
+	err := data.SetGob(packet)
+	if err != nil {
+		panic(err)
+	}
+	return data
+}
+
+func (packet *tweakSetPacket) Decode(req p2p.Data) error {
+	return req.GetGob(&packet)
+}
+
+func (packet *tweakSetPacket) DataType() string {
+	return ptTweakSet
+}
+
+/////
+
+type nodeDataSetPacket struct {
+	NodeID uint32
+	Path   []string
+	Value  string
+	Error  string
+}
+
+func newNodeDataSetPacket(nodeID uint32, path []string, value string) *nodeDataSetPacket {
+	return &nodeDataSetPacket{NodeID: nodeID, Path: path, Value: value}
+}
+
+func (packet *nodeDataSetPacket) Encode() p2p.Data {
+	data := p2p.Data{}
+	err := data.SetGob(packet)
+	if err != nil {
+		panic(err)
+	}
+	return data
+}
+
+func (packet *nodeDataSetPacket) Decode(req p2p.Data) error {
+	return req.GetGob(&packet)
+}
+
+func (packet *nodeDataSetPacket) DataType() string {
+	return ptNodeDataSet
+}
+
+/////
+
+type nodeInspectorSetPacket struct {
+	NodeID  uint32
+	Section string
+	Field   string
+	Value   string
+	Error   string
+}
+
+func newNodeInspectorSetPacket(nodeID uint32, section, field, value string) *nodeInspectorSetPacket {
+	return &nodeInspectorSetPacket{NodeID: nodeID, Section: section, Field: field, Value: value}
+}
+
+func (packet *nodeInspectorSetPacket) Encode() p2p.Data {
+	data := p2p.Data{}
+	err := data.SetGob(packet)
+	if err != nil {
+		panic(err)
+	}
+	return data
+}
+
+func (packet *nodeInspectorSetPacket) Decode(req p2p.Data) error {
+	return req.GetGob(&packet)
+}
+
+func (packet *nodeInspectorSetPacket) DataType() string {
+	return ptNodeInspectorSet
+}
+
+/////
+
+type actionInfo struct {
+	Name        string
+	Description string
+}
+
+type actionsPacket struct {
+	Actions []actionInfo
+}
+
+func newActionsPacket() *actionsPacket {
+	return &actionsPacket{}
+}
+
+func (packet *actionsPacket) Encode() p2p.Data {
+	data := p2p.Data{}
+	err := data.SetGob(packet)
+	if err != nil {
+		panic(err)
+	}
+	return data
+}
+
+func (packet *actionsPacket) Decode(req p2p.Data) error {
+	return req.GetGob(&packet)
+}
+
+func (packet *actionsPacket) DataType() string {
+	return ptActions
+}
+
+/////
+
+type actionRunPacket struct {
+	Name  string
+	Error string
+}
+
+func newActionRunPacket(name string) *actionRunPacket {
+	return &actionRunPacket{Name: name}
+}
+
+func (packet *actionRunPacket) Encode() p2p.Data {
+	data := p2p.Data{}
+	err := data.SetGob(packet)
+	if err != nil {
+		panic(err)
+	}
+	return data
+}
+
+func (packet *actionRunPacket) Decode(req p2p.Data) error {
+	return req.GetGob(&packet)
+}
+
+func (packet *actionRunPacket) DataType() string {
+	return ptActionRun
+}
+
+/////
+
+type commandInfo struct {
+	Name  string
+	Usage string
+	Args  []ArgSpec
+}
+
+type commandsPacket struct {
+	Commands []commandInfo
+}
+
+func newCommandsPacket() *commandsPacket {
+	return &commandsPacket{}
+}
+
+func (packet *commandsPacket) Encode() p2p.Data {
+	data := p2p.Data{}
+	err := data.SetGob(packet)
+	if err != nil {
+		panic(err)
+	}
+	return data
+}
+
+func (packet *commandsPacket) Decode(req p2p.Data) error {
+	return req.GetGob(&packet)
+}
+
+func (packet *commandsPacket) DataType() string {
+	return ptCommands
+}
+
+/////
+
+type commandRunPacket struct {
+	Line   string
+	Output string
+	Error  string
+}
+
+func newCommandRunPacket(line string) *commandRunPacket {
+	return &commandRunPacket{Line: line}
+}
+
+func (packet *commandRunPacket) Encode() p2p.Data {
+	data := p2p.Data{}
+	err := data.SetGob(packet)
+	if err != nil {
+		panic(err)
+	}
+	return data
+}
+
+func (packet *commandRunPacket) Decode(req p2p.Data) error {
+	return req.GetGob(&packet)
+}
+
+func (packet *commandRunPacket) DataType() string {
+	return ptCommandRun
+}
+
+/////
+
+type nodeSetVisiblePacket struct {
+	NodeID  uint32
+	Visible bool
+	Error   string
+}
+
+func newNodeSetVisiblePacket(nodeID uint32, visible bool) *nodeSetVisiblePacket {
+	return &nodeSetVisiblePacket{NodeID: nodeID, Visible: visible}
+}
+
+func (packet *nodeSetVisiblePacket) Encode() p2p.Data {
+	data := p2p.Data{}
+	err := data.SetGob(packet)
+	if err != nil {
+		panic(err)
+	}
+	return data
+}
+
+func (packet *nodeSetVisiblePacket) Decode(req p2p.Data) error {
+	return req.GetGob(&packet)
+}
+
+func (packet *nodeSetVisiblePacket) DataType() string {
+	return ptNodeSetVisible
+}
+
+/////
+
+type logsPacket struct {
+	After   uint64 // The sequence number of the last log record the terminal has received
+	Session int64  // The game's session ID; sequence numbers start over with each session
+	Records []logRecord
+}
+
+func newLogsPacket(after uint64) *logsPacket {
+	return &logsPacket{After: after}
+}
+
+func (packet *logsPacket) Encode() p2p.Data {
+	data := p2p.Data{}
+	err := data.SetGob(packet)
+	if err != nil {
+		panic(err)
+	}
+	return data
+}
+
+func (packet *logsPacket) Decode(req p2p.Data) error {
+	return req.GetGob(&packet)
+}
+
+func (packet *logsPacket) DataType() string {
+	return ptLogs
+}
+
+/////
+
+type crashPacket struct {
+	Crashed bool
+	Report  crashReport
+}
+
+func newCrashPacket() *crashPacket {
+	return &crashPacket{}
+}
+
+func (packet *crashPacket) Encode() p2p.Data {
+	data := p2p.Data{}
+	err := data.SetGob(packet)
+	if err != nil {
+		panic(err)
+	}
+	return data
+}
+
+func (packet *crashPacket) Decode(req p2p.Data) error {
+	return req.GetGob(&packet)
+}
+
+func (packet *crashPacket) DataType() string {
+	return ptCrash
+}
+
+/////
+
+// crashAckPacket is sent by the terminal once it's received and saved a crash report, so the game can continue
+// panicking.
+type crashAckPacket struct{}
+
+func newCrashAckPacket() *crashAckPacket {
+	return &crashAckPacket{}
+}
+
+func (packet *crashAckPacket) Encode() p2p.Data {
+	data := p2p.Data{}
+	err := data.SetGob(packet)
+	if err != nil {
+		panic(err)
+	}
+	return data
+}
+
+func (packet *crashAckPacket) Decode(req p2p.Data) error {
+	return req.GetGob(&packet)
+}
+
+func (packet *crashAckPacket) DataType() string {
+	return ptCrashAck
+}
+
+/////
+
+type cameraStatePacket struct {
+	Set       bool // Whether the camera's transform should be set to Transform, rather than retrieved
+	HasCamera bool
+	Transform tetra3d.Matrix4
+}
+
+func newCameraStatePacket() *cameraStatePacket {
+	return &cameraStatePacket{}
+}
+
+func (packet *cameraStatePacket) Encode() p2p.Data {
+	data := p2p.Data{}
+	err := data.SetGob(packet)
+	if err != nil {
+		panic(err)
+	}
+	return data
+}
+
+func (packet *cameraStatePacket) Decode(req p2p.Data) error {
+	return req.GetGob(&packet)
+}
+
+func (packet *cameraStatePacket) DataType() string {
+	return ptCameraState
+}
+
+/////
+
+type timeControlPacket struct {
+	Action int
+	Steps  int
+	Scale  float64
+	Paused bool
+}
+
+func newTimeControlPacket(action int) *timeControlPacket {
+	return &timeControlPacket{Action: action}
+}
+
+func (packet *timeControlPacket) Encode() p2p.Data {
+	data := p2p.Data{}
+	err := data.SetGob(packet)
+	if err != nil {
+		panic(err)
+	}
+	return data
+}
+
+func (packet *timeControlPacket) Decode(req p2p.Data) error {
+	return req.GetGob(&packet)
+}
+
+func (packet *timeControlPacket) DataType() string {
+	return ptTimeControl
+}
+
+/////
+
+type breakpointsPacket struct {
+	Breakpoints []breakpointInfo
+	Hits        []breakpointHit
+}
+
+func newBreakpointsPacket() *breakpointsPacket {
+	return &breakpointsPacket{}
+}
+
+func (packet *breakpointsPacket) Encode() p2p.Data {
+	data := p2p.Data{}
+	err := data.SetGob(packet)
+	if err != nil {
+		panic(err)
+	}
+	return data
+}
+
+func (packet *breakpointsPacket) Decode(req p2p.Data) error {
+	return req.GetGob(&packet)
+}
+
+func (packet *breakpointsPacket) DataType() string {
+	return ptBreakpoints
+}
+
+/////
+
+type breakpointEditPacket struct {
+	Action    int
+	ID        int
+	Condition string
+	Error     string
+}
+
+func newBreakpointEditPacket(action int) *breakpointEditPacket {
+	return &breakpointEditPacket{Action: action}
+}
+
+func (packet *breakpointEditPacket) Encode() p2p.Data {
+	data := p2p.Data{}
+	err := data.SetGob(packet)
+	if err != nil {
+		panic(err)
+	}
+	return data
+}
+
+func (packet *breakpointEditPacket) Decode(req p2p.Data) error {
+	return req.GetGob(&packet)
+}
+
+func (packet *breakpointEditPacket) DataType() string {
+	return ptBreakpointEdit
+}
+
+/////
+
+type inputPacket struct {
+	Action  int
+	Keys    []ebiten.Key
+	Button  ebiten.MouseButton
+	Stick   int
+	X, Y    float64
+	CursorX int
+	CursorY int
+	State   inputState
+
+	// Recording and replay
+	Offset        int
+	Ticks         []inputState
+	Recording     bool
+	RecordedTicks int
+	Replaying     bool
+	ReplayTick    int
+	ReplayLength  int
+}
+
+func newInputPacket(action int) *inputPacket {
+	return &inputPacket{Action: action}
+}
+
+func (packet *inputPacket) Encode() p2p.Data {
+	data := p2p.Data{}
+	err := data.SetGob(packet)
+	if err != nil {
+		panic(err)
+	}
+	return data
+}
+
+func (packet *inputPacket) Decode(req p2p.Data) error {
+	return req.GetGob(&packet)
+}
+
+func (packet *inputPacket) DataType() string {
+	return ptInput
+}
+
+/////
+
+type debugDrawPacket struct {
+	Action     int
+	Category   string
+	Categories []debugCategoryInfo
+}
+
+func newDebugDrawPacket(action int) *debugDrawPacket {
+	return &debugDrawPacket{Action: action}
+}
+
+func (packet *debugDrawPacket) Encode() p2p.Data {
+	data := p2p.Data{}
+	err := data.SetGob(packet)
+	if err != nil {
+		panic(err)
+	}
+	return data
+}
+
+func (packet *debugDrawPacket) Decode(req p2p.Data) error {
+	return req.GetGob(&packet)
+}
+
+func (packet *debugDrawPacket) DataType() string {
+	return ptDebugDraw
+}
+
+/////
+
+type overlaySettingsPacket struct {
+	Set      bool
+	Settings OverlaySettings
+}
+
+func newOverlaySettingsPacket() *overlaySettingsPacket {
+	return &overlaySettingsPacket{}
+}
+
+func (packet *overlaySettingsPacket) Encode() p2p.Data {
+	data := p2p.Data{}
+	err := data.SetGob(packet)
+	if err != nil {
+		panic(err)
+	}
+	return data
+}
+
+func (packet *overlaySettingsPacket) Decode(req p2p.Data) error {
+	return req.GetGob(&packet)
+}
+
+func (packet *overlaySettingsPacket) DataType() string {
+	return ptOverlaySettings
+}
+
+/////
+
+type sectorPacket struct {
+	Action          int
+	SectorRendering bool
+	RenderDepth     int
+	DrawOverlay     bool
+	SectorCount     int
+	Current         sectorInfo
+	HasCurrent      bool
+	Neighbors       []sectorInfo
+	Error           string
+}
+
+func newSectorPacket(action int) *sectorPacket {
+	return &sectorPacket{Action: action}
+}
+
+func (packet *sectorPacket) Encode() p2p.Data {
+	data := p2p.Data{}
+	err := data.SetGob(packet)
+	if err != nil {
+		panic(err)
+	}
+	return data
+}
+
+func (packet *sectorPacket) Decode(req p2p.Data) error {
+	return req.GetGob(&packet)
+}
+
+func (packet *sectorPacket) DataType() string {
+	return ptSectors
+}
+
+/////
+
+type worldPacket struct {
+	Set      string // The name of the worldSettings field to set from Settings; if blank, nothing's set
+	Settings worldSettings
+	Error    string
+}
+
+func newWorldPacket() *worldPacket {
+	return &worldPacket{}
+}
+
+func (packet *worldPacket) Encode() p2p.Data {
+	data := p2p.Data{}
+	err := data.SetGob(packet)
+	if err != nil {
+		panic(err)
+	}
+	return data
+}
+
+func (packet *worldPacket) Decode(req p2p.Data) error {
+	return req.GetGob(&packet)
+}
+
+func (packet *worldPacket) DataType() string {
+	return ptWorld
+}
+
+/////
+
+type lightsPacket struct {
+	Action     int
+	NodeID     uint32
+	Color      tetra3d.Color
+	Energy     float32
+	Range      float32
+	Lights     []lightInfo
+	CanRestore bool
+	Error      string
+}
+
+func newLightsPacket(action int) *lightsPacket {
+	return &lightsPacket{Action: action}
+}
+
+func (packet *lightsPacket) Encode() p2p.Data {
+	data := p2p.Data{}
+	err := data.SetGob(packet)
+	if err != nil {
+		panic(err)
+	}
+	return data
+}
+
+func (packet *lightsPacket) Decode(req p2p.Data) error {
+	return req.GetGob(&packet)
+}
+
+func (packet *lightsPacket) DataType() string {
+	return ptLights
+}
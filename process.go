@@ -0,0 +1,300 @@
+package tetraterm
+
+import (
+	"errors"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+// gameProcess supervises the game when it's launched by the terminal (i.e. with tetraterm run -- go run ./mygame).
+type gameProcess struct {
+	mutex   sync.Mutex
+	command []string
+	cmd     *exec.Cmd
+	done    chan struct{}
+	killed  bool
+	started bool // Whether the game's been started before, and so has a session to restore
+
+	// restartMutex keeps the game from being restarted several times at once.
+	restartMutex sync.Mutex
+}
+
+// StartGameProcess launches the game using the command given (like []string{"go", "run", "./mygame"}) as a
+// child process of the terminal. The game's stdout and stderr are displayed in the Game Process pane (F9),
+// which is shown when the game is started, and also offers keys to restart or kill it. The game is killed when
+// the Display is stopped.
+//
+// StartGameProcess can be called before Display.Start(); the game is launched once the Display is running, and
+// errors launching it are shown in the Game Process pane.
+func (display *Display) StartGameProcess(command []string) error {
+
+	if len(command) == 0 {
+		return errors.New("no command given to start the game with")
+	}
+
+	display.process.mutex.Lock()
+	display.process.command = command
+	display.process.mutex.Unlock()
+
+	// The pane's updated through App.QueueUpdate(), which blocks until the Display's running, so the game's
+	// started in the background.
+	go func() {
+
+		display.App.QueueUpdate(func() {
+			if !display.toolPaneVisible("process") {
+				display.toggleToolPane("process")
+			}
+		})
+
+		display.restartGameProcess()
+
+	}()
+
+	return nil
+
+}
+
+// restartGameProcess kills the game process if it's running, and then starts it again.
+func (display *Display) restartGameProcess() error {
+
+	process := &display.process
+
+	process.restartMutex.Lock()
+	defer process.restartMutex.Unlock()
+
+	process.mutex.Lock()
+	command := process.command
+	started := process.started
+	if len(command) > 0 {
+		process.started = true
+	}
+	process.mutex.Unlock()
+
+	if len(command) == 0 {
+		display.printProcessOutput("[yellow]The game wasn't started by the terminal, so it can't be restarted. Use tetraterm run -- <command> to start it.[-]")
+		return errors.New("the game wasn't started by the terminal")
+	}
+
+	// If the game's being restarted while the terminal's running, we restore the terminal's state afterwards;
+	// if the game isn't running (i.e. it failed to build), this is the last state the terminal saw.
+
+	var state sessionState
+	if started && display.running.Load() {
+		state = display.captureSessionState()
+	}
+
+	display.killGameProcess()
+
+	// The new game reuses the old one's node IDs, so the old node tree is forgotten; otherwise, the session would
+	// be restored onto tree nodes that are about to be replaced.
+	display.App.QueueUpdate(func() {
+		display.SceneNodesToTreeNodes = map[uint32]*tview.TreeNode{}
+		display.currentSceneTree = sceneNode{}
+		display.TreeView.SetCurrentNode(nil)
+	})
+
+	cmd := exec.Command(command[0], command[1:]...)
+	cmd.Stdout = &processOutputWriter{display: display}
+	cmd.Stderr = &processOutputWriter{display: display, stderr: true}
+	setProcessGroup(cmd)
+
+	display.printProcessOutput("[gray]$ " + tview.Escape(strings.Join(command, " ")) + "[-]")
+
+	if err := cmd.Start(); err != nil {
+		display.setProcessStatus("[red]Failed to Start[-]")
+		display.printProcessOutput("[red]" + tview.Escape(err.Error()) + "[-]")
+		return err
+	}
+
+	done := make(chan struct{})
+
+	process.mutex.Lock()
+	process.cmd = cmd
+	process.done = done
+	process.killed = false
+	process.mutex.Unlock()
+
+	display.setProcessStatus("[green]Running[-] (PID " + strconv.Itoa(cmd.Process.Pid) + ")")
+
+	go func() {
+
+		err := cmd.Wait()
+
+		process.mutex.Lock()
+		killed := process.killed
+		if process.cmd == cmd {
+			process.cmd = nil
+		}
+		process.mutex.Unlock()
+
+		close(done)
+
+		if killed {
+			display.setProcessStatus("[yellow]Killed[-]")
+			display.printProcessOutput("[yellow]The game was killed.[-]")
+		} else if err != nil {
+			code := -1
+			var exitErr *exec.ExitError
+			if errors.As(err, &exitErr) {
+				code = exitErr.ExitCode()
+			}
+			display.setProcessStatus("[red]Exited[-] (code " + strconv.Itoa(code) + ")")
+			display.printProcessOutput("[red]The game exited with code " + strconv.Itoa(code) + ".[-]")
+		} else {
+			display.setProcessStatus("Exited (code 0)")
+			display.printProcessOutput("[gray]The game exited with code 0.[-]")
+		}
+
+	}()
+
+	// The new game process starts a new server, so reconnect to it.
+	display.initClient()
+
+	if state.expanded != nil {
+		go display.restoreSessionState(state)
+	}
+
+	return nil
+
+}
+
+// killGameProcess kills the game process (and any processes it started, like the game binary started by
+// go run), waiting for it to exit.
+func (display *Display) killGameProcess() {
+
+	process := &display.process
+
+	process.mutex.Lock()
+	cmd := process.cmd
+	done := process.done
+	if cmd != nil {
+		process.killed = true
+	}
+	process.mutex.Unlock()
+
+	if cmd == nil {
+		return
+	}
+
+	killProcessGroup(cmd)
+
+	select {
+	case <-done:
+	case <-time.After(time.Second * 5):
+	}
+
+}
+
+// processOutputWriter writes the output of the game process to the Game Process pane, line by line.
+type processOutputWriter struct {
+	display *Display
+	stderr  bool
+	partial string
+}
+
+func (writer *processOutputWriter) Write(p []byte) (int, error) {
+
+	text := writer.partial + string(p)
+	lines := strings.Split(text, "\n")
+
+	// The last element is either empty or an unfinished line, which is held until the rest of it is written.
+	writer.partial = lines[len(lines)-1]
+
+	for _, line := range lines[:len(lines)-1] {
+
+		line = tview.TranslateANSI(tview.Escape(strings.TrimRight(line, "\r")))
+
+		if writer.stderr {
+			line = "[#ff8080]" + line + "[-]"
+		}
+
+		writer.display.printProcessOutput(line)
+
+	}
+
+	return len(p), nil
+
+}
+
+// printProcessOutput prints a line of text (which can contain color tags) to the Game Process pane. This is
+// safe to call from any goroutine.
+func (display *Display) printProcessOutput(text string) {
+	display.App.QueueUpdate(func() {
+		display.processOutput.Write([]byte(text + "\n"))
+		if display.followProcessOutput {
+			display.processOutput.ScrollToEnd()
+		}
+	})
+}
+
+// setProcessStatus sets the status shown in the Game Process pane. This is safe to call from any goroutine.
+func (display *Display) setProcessStatus(status string) {
+	display.App.QueueUpdate(func() {
+		display.processStatus.SetText("Game: " + status + "  [gray]R: Restart, K: Kill, C: Clear, F: Follow Output[-]")
+	})
+}
+
+// initProcessPane creates the Game Process tool pane, which displays the output of the game when it's launched
+// by the terminal.
+func (display *Display) initProcessPane() {
+
+	output := tview.NewTextView()
+	output.SetDynamicColors(true)
+	output.SetScrollable(true)
+	output.SetMaxLines(5000)
+	output.SetBackgroundColor(tcell.ColorDefault)
+
+	status := tview.NewTextView()
+	status.SetDynamicColors(true)
+	status.SetBackgroundColor(tcell.ColorDefault)
+
+	pane := tview.NewFlex()
+	pane.SetDirection(tview.FlexRow)
+	pane.SetBorder(true)
+	pane.SetTitle("[ Game Process ]")
+	pane.AddItem(output, 0, 1, true)
+	pane.AddItem(status, 1, 0, false)
+
+	display.addToolPane("process", tcell.KeyF9, pane)
+	display.processOutput = output
+	display.processStatus = status
+	display.followProcessOutput = true
+
+	display.processStatus.SetText("Game: Not Started by the Terminal  [gray]Run tetraterm run -- go run ./mygame to start it[-]")
+
+	output.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+
+		switch event.Rune() {
+
+		case 'r', 'R':
+			go display.restartGameProcess()
+			return nil
+
+		case 'k', 'K':
+			go display.killGameProcess()
+			return nil
+
+		case 'c', 'C':
+			output.Clear()
+			return nil
+
+		case 'f', 'F':
+			display.followProcessOutput = !display.followProcessOutput
+			if display.followProcessOutput {
+				output.ScrollToEnd()
+			}
+			return nil
+
+		}
+
+		return event
+
+	})
+
+}
@@ -0,0 +1,18 @@
+//go:build windows
+
+package tetraterm
+
+import (
+	"os/exec"
+	"strconv"
+)
+
+// setProcessGroup does nothing on Windows, as killProcessGroup() kills the command's process tree instead.
+func setProcessGroup(cmd *exec.Cmd) {}
+
+// killProcessGroup kills the command's process tree.
+func killProcessGroup(cmd *exec.Cmd) {
+	if err := exec.Command("taskkill", "/T", "/F", "/PID", strconv.Itoa(cmd.Process.Pid)).Run(); err != nil {
+		cmd.Process.Kill()
+	}
+}
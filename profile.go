@@ -0,0 +1,277 @@
+package tetraterm
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/pprof"
+	"runtime/trace"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+// The maximum number of bytes of a finished profile sent back to the terminal in a single packet.
+const profileChunkSize = 64 * 1024
+
+// profileCapture holds the state of the profile the Server is currently capturing (or has most recently captured).
+type profileCapture struct {
+	mutex     sync.Mutex
+	capturing bool
+	kind      int
+	data      []byte
+	err       error
+}
+
+func profileKindName(kind int) string {
+	switch kind {
+	case profileKindCPU:
+		return "CPU profile"
+	case profileKindHeap:
+		return "heap profile"
+	case profileKindTrace:
+		return "execution trace"
+	}
+	return "unknown profile"
+}
+
+// startProfile begins capturing a profile of the given kind in the background. CPU profiles and
+// execution traces run for the duration given; heap profiles are captured immediately.
+func (server *Server) startProfile(kind int, duration time.Duration) error {
+
+	capture := &server.profile
+
+	capture.mutex.Lock()
+	defer capture.mutex.Unlock()
+
+	if capture.capturing {
+		return errors.New("a " + profileKindName(capture.kind) + " is already being captured")
+	}
+
+	buffer := &bytes.Buffer{}
+
+	switch kind {
+
+	case profileKindCPU:
+		if err := pprof.StartCPUProfile(buffer); err != nil {
+			return err
+		}
+
+	case profileKindTrace:
+		if err := trace.Start(buffer); err != nil {
+			return err
+		}
+
+	case profileKindHeap:
+		// Heap profiles are a snapshot, so there's nothing to wait for; we just run a GC first so
+		// the profile reflects up-to-date allocation statistics.
+		runtime.GC()
+		err := pprof.Lookup("heap").WriteTo(buffer, 0)
+		capture.kind = kind
+		capture.data = buffer.Bytes()
+		capture.err = err
+		return err
+
+	default:
+		return errors.New("unknown profile type " + strconv.Itoa(kind))
+
+	}
+
+	capture.capturing = true
+	capture.kind = kind
+	capture.data = nil
+	capture.err = nil
+
+	go func() {
+
+		time.Sleep(duration)
+
+		if kind == profileKindCPU {
+			pprof.StopCPUProfile()
+		} else {
+			trace.Stop()
+		}
+
+		capture.mutex.Lock()
+		capture.data = buffer.Bytes()
+		capture.capturing = false
+		capture.mutex.Unlock()
+
+	}()
+
+	return nil
+
+}
+
+// fetchProfile fills out the packet with the state of the current profile capture, as well as the
+// chunk of the finished profile starting at the packet's offset.
+func (server *Server) fetchProfile(packet *profileFetchPacket) {
+
+	capture := &server.profile
+
+	capture.mutex.Lock()
+	defer capture.mutex.Unlock()
+
+	packet.Kind = capture.kind
+	packet.Capturing = capture.capturing
+	packet.Total = len(capture.data)
+
+	if capture.err != nil {
+		packet.Error = capture.err.Error()
+	}
+
+	if !capture.capturing && packet.Offset < len(capture.data) {
+		end := packet.Offset + profileChunkSize
+		if end > len(capture.data) {
+			end = len(capture.data)
+		}
+		packet.Chunk = capture.data[packet.Offset:end]
+	}
+
+}
+
+// initProfilePage creates the profiling page, which allows you to capture CPU and heap profiles, as
+// well as execution traces, from the running game.
+func (display *Display) initProfilePage() {
+
+	status := tview.NewTextView()
+	status.SetDynamicColors(true)
+	status.SetBackgroundColor(tcell.ColorDefault)
+	status.SetText("Choose a profile to capture.")
+
+	setStatus := func(text string) {
+		display.App.QueueUpdate(func() {
+			status.SetText(text)
+		})
+	}
+
+	form := tview.NewForm()
+	form.SetBackgroundColor(tcell.ColorDefault)
+	form.SetFieldBackgroundColor(tcell.ColorDarkSlateGray)
+	form.SetFieldTextColor(tcell.ColorLightBlue)
+	form.SetLabelColor(tcell.ColorLightBlue)
+
+	form.AddInputField("Duration (seconds): ", "5", 8, tview.InputFieldInteger, nil)
+
+	capture := func(kind int) {
+
+		durationField := form.GetFormItemByLabel("Duration (seconds): ").(*tview.InputField)
+		seconds, err := strconv.Atoi(durationField.GetText())
+		if err != nil || seconds <= 0 {
+			status.SetText("[red]Duration must be a positive number of seconds.")
+			return
+		}
+
+		if display.capturingProfile.Swap(true) {
+			status.SetText("[yellow]A profile is already being captured.")
+			return
+		}
+
+		go func() {
+
+			defer display.capturingProfile.Store(false)
+
+			path, err := display.captureProfile(kind, time.Duration(seconds)*time.Second, setStatus)
+			if err != nil {
+				setStatus("[red]Couldn't capture " + profileKindName(kind) + ": " + err.Error())
+			} else {
+				setStatus("[green]Saved " + profileKindName(kind) + " to " + path)
+			}
+
+		}()
+
+	}
+
+	form.AddButton("CPU Profile", func() { capture(profileKindCPU) })
+	form.AddButton("Heap Profile", func() { capture(profileKindHeap) })
+	form.AddButton("Trace", func() { capture(profileKindTrace) })
+	form.AddButton("Close", func() { display.Root.HidePage("profile") })
+
+	form.SetCancelFunc(func() {
+		display.Root.HidePage("profile")
+	})
+
+	layout := tview.NewFlex()
+	layout.SetDirection(tview.FlexRow)
+	layout.SetBorder(true)
+	layout.SetTitle("[ Profiling ]")
+	layout.AddItem(form, 0, 1, true)
+	layout.AddItem(status, 3, 0, false)
+
+	display.Root.AddPage("profile", newCenteredPrimitive(layout, 64, 12), true, false)
+
+}
+
+// captureProfile asks the server to capture a profile of the given kind, waits for it to finish, and then
+// downloads it, saving it to the Display's profile directory. The path of the saved file is returned.
+func (display *Display) captureProfile(kind int, duration time.Duration, setStatus func(text string)) (string, error) {
+
+	start, err := display.sendRequest(newProfileStartPacket(kind, duration))
+	if err != nil {
+		return "", err
+	}
+
+	if errText := start.(*profileStartPacket).Error; errText != "" {
+		return "", errors.New(errText)
+	}
+
+	data := []byte{}
+	startTime := time.Now()
+
+	for {
+
+		res, err := display.sendRequest(newProfileFetchPacket(len(data)))
+		if err != nil {
+			return "", err
+		}
+
+		fetch := res.(*profileFetchPacket)
+
+		if fetch.Error != "" {
+			return "", errors.New(fetch.Error)
+		}
+
+		if fetch.Capturing {
+			remaining := duration - time.Since(startTime)
+			if remaining < 0 {
+				remaining = 0
+			}
+			setStatus(fmt.Sprintf("Capturing %s (%.0fs remaining)...", profileKindName(kind), remaining.Seconds()))
+			time.Sleep(time.Millisecond * 250)
+			continue
+		}
+
+		data = append(data, fetch.Chunk...)
+
+		if len(data) >= fetch.Total || len(fetch.Chunk) == 0 {
+			break
+		}
+
+		setStatus(fmt.Sprintf("Downloading %s (%d/%d KB)...", profileKindName(kind), len(data)/1024, fetch.Total/1024))
+
+	}
+
+	extension := ".pprof"
+	prefix := "cpu"
+	if kind == profileKindHeap {
+		prefix = "heap"
+	} else if kind == profileKindTrace {
+		prefix = "trace"
+		extension = ".trace"
+	}
+
+	path := filepath.Join(display.ProfileDirectory, "tetraterm-"+prefix+"-"+time.Now().Format("20060102-150405")+extension)
+
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return "", err
+	}
+
+	return path, nil
+
+}
@@ -0,0 +1,251 @@
+package tetraterm
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+// The version of the input recording file format.
+const inputRecordingVersion = 1
+
+// How many ticks of input are sent in each packet when transferring recordings between the game and the terminal,
+// as large packets can exceed the connection's timeout.
+const recordingChunkSize = 600
+
+// inputRecording is an input recording as it's saved to disk; each tick holds the full input state the game saw
+// through the Server's input functions on that tick.
+type inputRecording struct {
+	Version  int
+	Recorded time.Time
+	Ticks    []inputState
+}
+
+// Replaying returns if the terminal is replaying an input recording. While replaying, the Server's input
+// functions (Server.IsKeyPressed() and friends) only report the recorded input, ignoring the real keyboard,
+// mouse, and gamepads. For replays to be deterministic, the game should be in the same state as when recording
+// started (i.e. by restarting the level), and should only read input through the Server.
+func (server *Server) Replaying() bool {
+	server.input.mutex.Lock()
+	defer server.input.mutex.Unlock()
+	return server.input.replaying
+}
+
+// recordingStatus fills out the recording and replay status of the packet given.
+func (input *virtualInput) recordingStatus(packet *inputPacket) {
+	input.mutex.Lock()
+	defer input.mutex.Unlock()
+	packet.Recording = input.recording
+	packet.RecordedTicks = len(input.recorded)
+	packet.Replaying = input.replaying
+	packet.ReplayTick = input.replayTick
+	packet.ReplayLength = len(input.replay)
+}
+
+// startInputRecording starts recording the game's input each tick.
+func (display *Display) startInputRecording() error {
+	_, err := display.sendRequest(newInputPacket(inputRecordStart))
+	return err
+}
+
+// stopInputRecording stops recording the game's input, saving the recording to RecordingDirectory and returning
+// the path it was saved to.
+func (display *Display) stopInputRecording() (string, error) {
+
+	res, err := display.sendRequest(newInputPacket(inputRecordStop))
+	if err != nil {
+		return "", err
+	}
+
+	ticks := []inputState{}
+
+	for len(ticks) < res.(*inputPacket).RecordedTicks {
+
+		packet := newInputPacket(inputRecordFetch)
+		packet.Offset = len(ticks)
+
+		chunk, err := display.sendRequest(packet)
+		if err != nil {
+			return "", err
+		}
+
+		if len(chunk.(*inputPacket).Ticks) == 0 {
+			return "", errors.New("the recording changed while it was being fetched from the game")
+		}
+
+		ticks = append(ticks, chunk.(*inputPacket).Ticks...)
+
+	}
+
+	if len(ticks) == 0 {
+		return "", errors.New("no input was recorded (recording may not have been started, or the game was paused)")
+	}
+
+	recording := inputRecording{
+		Version:  inputRecordingVersion,
+		Recorded: time.Now(),
+		Ticks:    ticks,
+	}
+
+	data, err := json.Marshal(recording)
+	if err != nil {
+		return "", err
+	}
+
+	path := filepath.Join(display.RecordingDirectory, "tetraterm-input-"+recording.Recorded.Format("20060102-150405")+".json")
+
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return "", err
+	}
+
+	return path, nil
+
+}
+
+// replayInputRecording loads the input recording at the path given and replays it in the game, returning the
+// number of ticks in the recording.
+func (display *Display) replayInputRecording(path string) (int, error) {
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+
+	recording := inputRecording{}
+
+	if err := json.Unmarshal(data, &recording); err != nil {
+		return 0, errors.New("couldn't read input recording: " + err.Error())
+	}
+
+	if recording.Version != inputRecordingVersion {
+		return 0, errors.New("unsupported input recording version " + strconv.Itoa(recording.Version))
+	}
+
+	if len(recording.Ticks) == 0 {
+		return 0, errors.New("the input recording is empty")
+	}
+
+	// Recordings may have been edited by hand, so make sure each tick's maps exist.
+	for i, state := range recording.Ticks {
+		if state.Keys == nil {
+			recording.Ticks[i].Keys = map[ebiten.Key]bool{}
+		}
+		if state.MouseButtons == nil {
+			recording.Ticks[i].MouseButtons = map[ebiten.MouseButton]bool{}
+		}
+		if state.Axes == nil {
+			recording.Ticks[i].Axes = map[ebiten.StandardGamepadAxis]float64{}
+		}
+	}
+
+	for offset := 0; offset < len(recording.Ticks); offset += recordingChunkSize {
+
+		end := offset + recordingChunkSize
+		if end > len(recording.Ticks) {
+			end = len(recording.Ticks)
+		}
+
+		packet := newInputPacket(inputReplayLoad)
+		packet.Offset = offset
+		packet.Ticks = recording.Ticks[offset:end]
+
+		if _, err := display.sendRequest(packet); err != nil {
+			return 0, err
+		}
+
+	}
+
+	if _, err := display.sendRequest(newInputPacket(inputReplayStart)); err != nil {
+		return 0, err
+	}
+
+	return len(recording.Ticks), nil
+
+}
+
+// stopInputReplay stops replaying an input recording.
+func (display *Display) stopInputReplay() error {
+	_, err := display.sendRequest(newInputPacket(inputReplayStop))
+	return err
+}
+
+// runRecordCommand runs the record console command (record <start|stop>).
+func (display *Display) runRecordCommand(args []string) (string, error) {
+
+	if len(args) != 1 {
+		return "", errors.New("usage: record <start|stop>")
+	}
+
+	switch strings.ToLower(args[0]) {
+
+	case "start":
+		if err := display.startInputRecording(); err != nil {
+			return "", err
+		}
+		return "recording input; run record stop to save it", nil
+
+	case "stop":
+		path, err := display.stopInputRecording()
+		if err != nil {
+			return "", err
+		}
+		return "saved input recording to " + path, nil
+
+	}
+
+	return "", errors.New("usage: record <start|stop>")
+
+}
+
+// runReplayCommand runs the replay console command (replay <file|stop>).
+func (display *Display) runReplayCommand(args []string) (string, error) {
+
+	if len(args) != 1 {
+		return "", errors.New("usage: replay <file|stop>")
+	}
+
+	if strings.EqualFold(args[0], "stop") {
+		if err := display.stopInputReplay(); err != nil {
+			return "", err
+		}
+		return "stopped replaying input", nil
+	}
+
+	ticks, err := display.replayInputRecording(args[0])
+	if err != nil {
+		return "", err
+	}
+
+	return "replaying " + strconv.Itoa(ticks) + " ticks of input from " + args[0], nil
+
+}
+
+// formatRecordingStatus returns the recording and replay status from the packet given for display in the
+// Input pane, including a progress bar for replays.
+func formatRecordingStatus(packet *inputPacket) string {
+
+	if packet.Replaying && packet.ReplayLength > 0 {
+
+		const barWidth = 30
+
+		filled := packet.ReplayTick * barWidth / packet.ReplayLength
+
+		return "[skyblue]Replaying:[-] " + strings.Repeat("█", filled) + "[gray]" + strings.Repeat("░", barWidth-filled) + "[-] " +
+			strconv.Itoa(packet.ReplayTick) + " / " + strconv.Itoa(packet.ReplayLength) + " ticks" +
+			" (" + strconv.Itoa(packet.ReplayTick*100/packet.ReplayLength) + "%)"
+
+	}
+
+	if packet.Recording {
+		return "[red]● Recording:[-] " + strconv.Itoa(packet.RecordedTicks) + " ticks"
+	}
+
+	return "Recording: [gray]Stopped[-]"
+
+}
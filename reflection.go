@@ -0,0 +1,301 @@
+package tetraterm
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	// How deeply values are serialized; values nested deeper than this are summarized.
+	valueTreeMaxDepth = 8
+	// How many elements of a slice, array or map are serialized.
+	valueTreeMaxElements = 100
+	// How many values in total are serialized for a single tree.
+	valueTreeMaxNodes = 5000
+)
+
+// valueNode is a serialized Go value, sent from the Server to the Display so it can be rendered as
+// an expandable tree.
+type valueNode struct {
+	Name     string
+	Type     string
+	Value    string
+	Editable bool // Whether the value is an exported, settable scalar that can be edited from the terminal
+	Children []valueNode
+}
+
+// String returns the valueNode's name, type and value for display in a tree.
+func (node valueNode) String() string {
+	text := node.Name
+	if node.Type != "" {
+		text += " (" + node.Type + ")"
+	}
+	if node.Value != "" {
+		text += ": " + node.Value
+	}
+	return text
+}
+
+// newValueTree serializes the value given into a tree of valueNodes using reflection.
+func newValueTree(name string, value any) valueNode {
+	budget := valueTreeMaxNodes
+	return reflectValueTree(name, reflect.ValueOf(value), 0, &budget)
+}
+
+func reflectValueTree(name string, value reflect.Value, depth int, budget *int) valueNode {
+
+	node := valueNode{Name: name}
+
+	*budget--
+
+	if !value.IsValid() {
+		node.Value = "nil"
+		return node
+	}
+
+	node.Type = value.Type().String()
+	node.Editable = value.CanSet() && isScalar(value.Kind())
+
+	// Interfaces and pointers are transparent; we display what they point to.
+	for value.Kind() == reflect.Interface || value.Kind() == reflect.Pointer {
+		if value.IsNil() {
+			node.Value = "nil"
+			return node
+		}
+		value = value.Elem()
+	}
+
+	if value.CanInterface() {
+		if stringer, ok := value.Interface().(fmt.Stringer); ok && value.Kind() != reflect.Slice && value.Kind() != reflect.Map {
+			node.Value = stringer.String()
+		}
+	}
+
+	if depth >= valueTreeMaxDepth || *budget <= 0 {
+		if node.Value == "" {
+			node.Value = "..."
+		}
+		return node
+	}
+
+	switch value.Kind() {
+
+	case reflect.Struct:
+		for i := 0; i < value.NumField(); i++ {
+			node.Children = append(node.Children, reflectValueTree(value.Type().Field(i).Name, value.Field(i), depth+1, budget))
+		}
+
+	case reflect.Slice, reflect.Array:
+		if value.Kind() == reflect.Slice && value.IsNil() {
+			node.Value = "nil"
+			break
+		}
+		node.Value = "len " + strconv.Itoa(value.Len())
+		for i := 0; i < value.Len() && i < valueTreeMaxElements; i++ {
+			node.Children = append(node.Children, reflectValueTree("["+strconv.Itoa(i)+"]", value.Index(i), depth+1, budget))
+		}
+
+	case reflect.Map:
+		if value.IsNil() {
+			node.Value = "nil"
+			break
+		}
+		node.Value = "len " + strconv.Itoa(value.Len())
+		keys := value.MapKeys()
+		sort.Slice(keys, func(i, j int) bool { return formatScalar(keys[i]) < formatScalar(keys[j]) })
+		for i, key := range keys {
+			if i >= valueTreeMaxElements {
+				break
+			}
+			node.Children = append(node.Children, reflectValueTree("["+formatScalar(key)+"]", value.MapIndex(key), depth+1, budget))
+		}
+
+	default:
+		if node.Value == "" {
+			node.Value = formatScalar(value)
+		}
+
+	}
+
+	return node
+
+}
+
+// formatScalar formats a non-container value as a string. This works on unexported struct fields as well,
+// as it doesn't rely on reflect.Value.Interface().
+func formatScalar(value reflect.Value) string {
+
+	switch value.Kind() {
+	case reflect.Bool:
+		return strconv.FormatBool(value.Bool())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(value.Int(), 10)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return strconv.FormatUint(value.Uint(), 10)
+	case reflect.Float32:
+		return strconv.FormatFloat(value.Float(), 'f', -1, 32)
+	case reflect.Float64:
+		return strconv.FormatFloat(value.Float(), 'f', -1, 64)
+	case reflect.Complex64, reflect.Complex128:
+		return strconv.FormatComplex(value.Complex(), 'f', -1, 128)
+	case reflect.String:
+		return strconv.Quote(value.String())
+	case reflect.Interface, reflect.Pointer:
+		if value.IsNil() {
+			return "nil"
+		}
+		return formatScalar(value.Elem())
+	case reflect.Func, reflect.Chan, reflect.UnsafePointer:
+		if value.IsNil() {
+			return "nil"
+		}
+		return fmt.Sprintf("%#x", value.Pointer())
+	}
+
+	return "{...}"
+
+}
+
+func isScalar(kind reflect.Kind) bool {
+	switch kind {
+	case reflect.Bool, reflect.String, reflect.Float32, reflect.Float64,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return true
+	}
+	return false
+}
+
+// parseScalar parses the text given as a value of the scalar type given.
+func parseScalar(valueType reflect.Type, text string) (reflect.Value, error) {
+
+	value := reflect.New(valueType).Elem()
+
+	switch valueType.Kind() {
+
+	case reflect.Bool:
+		b, err := strconv.ParseBool(strings.TrimSpace(text))
+		if err != nil {
+			return value, err
+		}
+		value.SetBool(b)
+
+	case reflect.String:
+		// Strings may be entered quoted (as they're displayed) or unquoted.
+		if unquoted, err := strconv.Unquote(text); err == nil {
+			text = unquoted
+		}
+		value.SetString(text)
+
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(strings.TrimSpace(text), valueType.Bits())
+		if err != nil {
+			return value, err
+		}
+		value.SetFloat(f)
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(strings.TrimSpace(text), 10, valueType.Bits())
+		if err != nil {
+			return value, err
+		}
+		value.SetInt(i)
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		u, err := strconv.ParseUint(strings.TrimSpace(text), 10, valueType.Bits())
+		if err != nil {
+			return value, err
+		}
+		value.SetUint(u)
+
+	default:
+		return value, errors.New(valueType.String() + " values can't be edited")
+
+	}
+
+	return value, nil
+
+}
+
+// resolveValuePath follows the path given (made up of the names of valueNodes, as created by newValueTree)
+// down from the root value, returning the value found at the end.
+func resolveValuePath(root reflect.Value, path []string) (reflect.Value, error) {
+
+	value := root
+
+	for _, name := range path {
+
+		for value.Kind() == reflect.Interface || value.Kind() == reflect.Pointer {
+			if value.IsNil() {
+				return value, errors.New("can't follow nil value to " + name)
+			}
+			value = value.Elem()
+		}
+
+		switch value.Kind() {
+
+		case reflect.Struct:
+			field, exists := value.Type().FieldByName(name)
+			if !exists || len(field.Index) != 1 {
+				return value, errors.New("no field named " + name)
+			}
+			value = value.Field(field.Index[0])
+
+		case reflect.Slice, reflect.Array:
+			index, err := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(name, "["), "]"))
+			if err != nil || index < 0 || index >= value.Len() {
+				return value, errors.New("no element " + name)
+			}
+			value = value.Index(index)
+
+		case reflect.Map:
+			found := false
+			for _, key := range value.MapKeys() {
+				if "["+formatScalar(key)+"]" == name {
+					value = value.MapIndex(key)
+					found = true
+					break
+				}
+			}
+			if !found {
+				return value, errors.New("no map entry " + name)
+			}
+
+		default:
+			return value, errors.New("can't find " + name + " in " + value.Type().String())
+
+		}
+
+	}
+
+	return value, nil
+
+}
+
+// setValueAtPath sets the scalar value at the path given (see resolveValuePath()) to the value parsed from
+// the text given.
+func setValueAtPath(root reflect.Value, path []string, text string) error {
+
+	target, err := resolveValuePath(root, path)
+	if err != nil {
+		return err
+	}
+
+	if !target.CanSet() || !isScalar(target.Kind()) {
+		return errors.New(strings.Join(path, ".") + " can't be edited")
+	}
+
+	value, err := parseScalar(target.Type(), text)
+	if err != nil {
+		return err
+	}
+
+	target.Set(value)
+
+	return nil
+
+}
@@ -0,0 +1,790 @@
+package tetraterm
+
+import (
+	"errors"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/solarlune/tetra3d"
+	"github.com/solarlune/tetra3d/math32"
+)
+
+// The maximum number of scripts that can be run from each other through exec at once.
+const maxExecDepth = 8
+
+// sceneCommand is a command built into the console that manipulates the game's scene by sending the
+// same packets the Display does when using the node tree.
+type sceneCommand struct {
+	name  string
+	usage string
+	args  []ArgSpec // Used for completion in the console
+	run   func(display *Display, args []string) (string, error)
+}
+
+// sceneCommands returns the commands built into the console. Nodes can be given as paths starting from the
+// root (/Root/Player) or relative to any node in the tree (Player, Enemies/*); path elements can contain
+// wildcards, as in path.Match().
+func sceneCommands() []sceneCommand {
+
+	return []sceneCommand{
+		{
+			name:  "help",
+			usage: "help",
+			run: func(display *Display, args []string) (string, error) {
+				return display.consoleHelp(), nil
+			},
+		},
+		{
+			name:  "select",
+			usage: "select <node>",
+			args:  []ArgSpec{{Name: "node", Type: ArgNode}},
+			run: func(display *Display, args []string) (string, error) {
+
+				if len(args) != 1 {
+					return "", errors.New("usage: select <node>")
+				}
+
+				nodes, err := display.findSceneNodes(args[0])
+				if err != nil {
+					return "", err
+				}
+
+				if err := display.selectSceneNode(nodes[0]); err != nil {
+					return "", err
+				}
+
+				return "selected " + nodes[0].path, nil
+
+			},
+		},
+		{
+			name:  "set",
+			usage: "set <node>.<position|visible> <value...>",
+			args:  []ArgSpec{{Name: "node.property", Type: ArgNode}},
+			run: func(display *Display, args []string) (string, error) {
+
+				if len(args) < 2 {
+					return "", errors.New("usage: set <node>.<position|visible> <value...>")
+				}
+
+				dot := strings.LastIndex(args[0], ".")
+				if dot < 0 {
+					return "", errors.New("no property given; usage: set <node>.<position|visible> <value...>")
+				}
+
+				property := strings.ToLower(args[0][dot+1:])
+
+				nodes, err := display.findSceneNodes(args[0][:dot])
+				if err != nil {
+					return "", err
+				}
+
+				switch property {
+
+				case "position", "pos":
+
+					target, err := parseCommandVector(args[1:])
+					if err != nil {
+						return "", err
+					}
+
+					// Descendants of matched nodes keep their positions relative to them, as with move.
+					for _, node := range topLevelSceneNodes(nodes) {
+
+						if err := display.selectSceneNode(node); err != nil {
+							return "", err
+						}
+
+						res, err := display.sendRequest(newNodeInfoPacket())
+						if err != nil {
+							return "", err
+						}
+
+						// Nodes can only be moved relative to their current position, so we move by the difference.
+						position := res.(*nodeInfoPacket).Position
+						if _, err := display.sendRequest(newNodeMovePacket(target.X-position.X, target.Y-position.Y, target.Z-position.Z)); err != nil {
+							return "", err
+						}
+
+					}
+
+				case "visible":
+
+					if len(args) != 2 {
+						return "", errors.New("usage: set <node>.visible <true|false>")
+					}
+
+					visible, err := strconv.ParseBool(args[1])
+					if err != nil {
+						return "", err
+					}
+
+					if err := display.setSceneNodesVisible(nodes, visible); err != nil {
+						return "", err
+					}
+
+				default:
+					return "", errors.New("unknown property " + strconv.Quote(property) + "; properties are position and visible")
+
+				}
+
+				return "set " + property + " of " + describeSceneNodes(nodes), nil
+
+			},
+		},
+		{
+			name:  "move",
+			usage: "move <node> <x> <y> <z>",
+			args:  []ArgSpec{{Name: "node", Type: ArgNode}},
+			run: func(display *Display, args []string) (string, error) {
+
+				if len(args) != 4 {
+					return "", errors.New("usage: move <node> <x> <y> <z>")
+				}
+
+				nodes, err := display.findSceneNodes(args[0])
+				if err != nil {
+					return "", err
+				}
+
+				// Descendants of matched nodes are dropped, as operating on their ancestors already affects them.
+				nodes = topLevelSceneNodes(nodes)
+
+				offset, err := parseCommandVector(args[1:])
+				if err != nil {
+					return "", err
+				}
+
+				for _, node := range nodes {
+					if err := display.selectSceneNode(node); err != nil {
+						return "", err
+					}
+					if _, err := display.sendRequest(newNodeMovePacket(offset.X, offset.Y, offset.Z)); err != nil {
+						return "", err
+					}
+				}
+
+				return "moved " + describeSceneNodes(nodes), nil
+
+			},
+		},
+		{
+			name:  "rotate",
+			usage: "rotate <node> <x|y|z> <degrees>",
+			args:  []ArgSpec{{Name: "node", Type: ArgNode}},
+			run: func(display *Display, args []string) (string, error) {
+
+				if len(args) != 3 {
+					return "", errors.New("usage: rotate <node> <x|y|z> <degrees>")
+				}
+
+				nodes, err := display.findSceneNodes(args[0])
+				if err != nil {
+					return "", err
+				}
+
+				// Descendants of matched nodes are dropped, as operating on their ancestors already affects them.
+				nodes = topLevelSceneNodes(nodes)
+
+				axis := tetra3d.Vector3{}
+				switch strings.ToLower(args[1]) {
+				case "x":
+					axis.X = 1
+				case "y":
+					axis.Y = 1
+				case "z":
+					axis.Z = 1
+				default:
+					return "", errors.New("unknown axis " + strconv.Quote(args[1]) + "; axes are x, y, and z")
+				}
+
+				degrees, err := strconv.ParseFloat(args[2], 32)
+				if err != nil {
+					return "", err
+				}
+
+				for _, node := range nodes {
+					if err := display.selectSceneNode(node); err != nil {
+						return "", err
+					}
+					if _, err := display.sendRequest(newNodeRotatePacket(axis.X, axis.Y, axis.Z, math32.ToRadians(float32(degrees)))); err != nil {
+						return "", err
+					}
+				}
+
+				return "rotated " + describeSceneNodes(nodes), nil
+
+			},
+		},
+		{
+			name:  "hide",
+			usage: "hide <nodes>",
+			args:  []ArgSpec{{Name: "nodes", Type: ArgNode}},
+			run: func(display *Display, args []string) (string, error) {
+				return display.runVisibilityCommand(args, false)
+			},
+		},
+		{
+			name:  "show",
+			usage: "show <nodes>",
+			args:  []ArgSpec{{Name: "nodes", Type: ArgNode}},
+			run: func(display *Display, args []string) (string, error) {
+				return display.runVisibilityCommand(args, true)
+			},
+		},
+		{
+			name:  "clone",
+			usage: "clone <name> [under <parent>]",
+			args:  []ArgSpec{{Name: "name", Type: ArgString}, {Name: "under", Type: ArgString}, {Name: "parent", Type: ArgNode}},
+			run: func(display *Display, args []string) (string, error) {
+
+				if len(args) != 1 && (len(args) != 3 || args[1] != "under") {
+					return "", errors.New("usage: clone <name> [under <parent>]")
+				}
+
+				packet := newNodeCreatePacket()
+				packet.NodeToCreate = args[0]
+
+				if len(args) == 3 {
+					parents, err := display.findSceneNodes(args[2])
+					if err != nil {
+						return "", err
+					}
+					packet.Parent = parents[0].NodeID
+					packet.HasParent = true
+				}
+
+				res, err := display.sendRequest(packet)
+				if err != nil {
+					return "", err
+				}
+
+				if res.(*nodeCreatePacket).SceneTree.Name == "" {
+					return "", errors.New("no node named " + strconv.Quote(args[0]) + " found to clone")
+				}
+
+				created := res.(*nodeCreatePacket)
+				display.updateSceneTree(created.SceneTree, created.NewSelectedNode)
+
+				return "cloned " + args[0], nil
+
+			},
+		},
+		{
+			name:  "delete",
+			usage: "delete [nodes]",
+			args:  []ArgSpec{{Name: "nodes", Type: ArgNode}},
+			run: func(display *Display, args []string) (string, error) {
+
+				if len(args) > 1 {
+					return "", errors.New("usage: delete [nodes]")
+				}
+
+				// Without any nodes given, the selected node is deleted.
+				if len(args) == 0 {
+					return "deleted the selected node", display.deleteSelectedNode()
+				}
+
+				nodes, err := display.findSceneNodes(args[0])
+				if err != nil {
+					return "", err
+				}
+
+				// Descendants of matched nodes are dropped, as operating on their ancestors already affects them.
+				nodes = topLevelSceneNodes(nodes)
+
+				for _, node := range nodes {
+					if err := display.selectSceneNode(node); err != nil {
+						return "", err
+					}
+					if err := display.deleteSelectedNode(); err != nil {
+						return "", err
+					}
+				}
+
+				return "deleted " + describeSceneNodes(nodes), nil
+
+			},
+		},
+		{
+			name:  "reset",
+			usage: "reset [nodes]",
+			args:  []ArgSpec{{Name: "nodes", Type: ArgNode}},
+			run: func(display *Display, args []string) (string, error) {
+
+				if len(args) > 1 {
+					return "", errors.New("usage: reset [nodes]")
+				}
+
+				if len(args) == 0 {
+					_, err := display.sendRequest(newNodeResetPacket())
+					return "reset the selected node", err
+				}
+
+				nodes, err := display.findSceneNodes(args[0])
+				if err != nil {
+					return "", err
+				}
+
+				// Descendants of matched nodes are dropped, as operating on their ancestors already affects them.
+				nodes = topLevelSceneNodes(nodes)
+
+				for _, node := range nodes {
+					if err := display.selectSceneNode(node); err != nil {
+						return "", err
+					}
+					if _, err := display.sendRequest(newNodeResetPacket()); err != nil {
+						return "", err
+					}
+				}
+
+				return "reset " + describeSceneNodes(nodes), nil
+
+			},
+		},
+		{
+			name:  "key",
+			usage: "key <tap|hold|release> <keys...>",
+			run: func(display *Display, args []string) (string, error) {
+				return display.runKeyCommand(args)
+			},
+		},
+		{
+			name:  "stick",
+			usage: "stick <left|right> <x> <y>",
+			args:  []ArgSpec{{Name: "stick", Type: ArgString}, {Name: "x", Type: ArgFloat}, {Name: "y", Type: ArgFloat}},
+			run: func(display *Display, args []string) (string, error) {
+
+				if len(args) != 3 {
+					return "", errors.New("usage: stick <left|right> <x> <y>")
+				}
+
+				packet := newInputPacket(inputStick)
+
+				switch strings.ToLower(args[0]) {
+				case "left":
+					packet.Stick = stickLeft
+				case "right":
+					packet.Stick = stickRight
+				default:
+					return "", errors.New("usage: stick <left|right> <x> <y>")
+				}
+
+				var err error
+				if packet.X, err = strconv.ParseFloat(args[1], 64); err != nil {
+					return "", err
+				}
+				if packet.Y, err = strconv.ParseFloat(args[2], 64); err != nil {
+					return "", err
+				}
+
+				state, err := display.sendInput(packet)
+				if err != nil {
+					return "", err
+				}
+
+				return describeInputState(state), nil
+
+			},
+		},
+		{
+			name:  "click",
+			usage: "click <x> <y> [left|right|middle]",
+			args:  []ArgSpec{{Name: "x", Type: ArgInt}, {Name: "y", Type: ArgInt}, {Name: "button", Type: ArgString, Optional: true}},
+			run: func(display *Display, args []string) (string, error) {
+
+				if len(args) != 2 && len(args) != 3 {
+					return "", errors.New("usage: click <x> <y> [left|right|middle]")
+				}
+
+				packet := newInputPacket(inputClick)
+
+				var err error
+				if packet.CursorX, err = strconv.Atoi(args[0]); err != nil {
+					return "", err
+				}
+				if packet.CursorY, err = strconv.Atoi(args[1]); err != nil {
+					return "", err
+				}
+
+				packet.Button = ebiten.MouseButtonLeft
+				if len(args) == 3 {
+					if packet.Button, err = parseMouseButton(args[2]); err != nil {
+						return "", err
+					}
+				}
+
+				if _, err := display.sendInput(packet); err != nil {
+					return "", err
+				}
+
+				return "clicked at " + args[0] + ", " + args[1], nil
+
+			},
+		},
+		{
+			name:  "input",
+			usage: "input [reset]",
+			run: func(display *Display, args []string) (string, error) {
+
+				packet := newInputPacket(inputQuery)
+
+				if len(args) == 1 && strings.EqualFold(args[0], "reset") {
+					packet.Action = inputReleaseAll
+				} else if len(args) > 0 {
+					return "", errors.New("usage: input [reset]")
+				}
+
+				state, err := display.sendInput(packet)
+				if err != nil {
+					return "", err
+				}
+
+				return describeInputState(state), nil
+
+			},
+		},
+		{
+			name:  "record",
+			usage: "record <start|stop>",
+			run: func(display *Display, args []string) (string, error) {
+				return display.runRecordCommand(args)
+			},
+		},
+		{
+			name:  "replay",
+			usage: "replay <file|stop>",
+			args:  []ArgSpec{{Name: "file", Type: ArgString}},
+			run: func(display *Display, args []string) (string, error) {
+				return display.runReplayCommand(args)
+			},
+		},
+		{
+			name:  "exec",
+			usage: "exec <file>",
+			args:  []ArgSpec{{Name: "file", Type: ArgString}},
+			run: func(display *Display, args []string) (string, error) {
+
+				if len(args) != 1 {
+					return "", errors.New("usage: exec <file>")
+				}
+
+				// Scripts can exec other scripts, but not endlessly (i.e. a script that execs itself).
+				if display.execDepth.Add(1) > maxExecDepth {
+					display.execDepth.Add(-1)
+					return "", errors.New("scripts can only be nested " + strconv.Itoa(maxExecDepth) + " deep; does " + args[0] + " exec itself?")
+				}
+				defer display.execDepth.Add(-1)
+
+				script, err := os.ReadFile(args[0])
+				if err != nil {
+					return "", err
+				}
+
+				for i, line := range strings.Split(string(script), "\n") {
+
+					line = strings.TrimSpace(line)
+
+					// Empty lines and comments are skipped.
+					if line == "" || strings.HasPrefix(line, "#") {
+						continue
+					}
+
+					if !display.runConsoleLine(line) {
+						return "", errors.New("stopped at line " + strconv.Itoa(i+1) + " of " + args[0])
+					}
+
+				}
+
+				return "ran " + args[0], nil
+
+			},
+		},
+	}
+
+}
+
+// findSceneCommand returns the built-in scene command with the given name.
+func findSceneCommand(name string) (sceneCommand, bool) {
+	for _, c := range sceneCommands() {
+		if c.name == name {
+			return c, true
+		}
+	}
+	return sceneCommand{}, false
+}
+
+// matchedSceneNode is a node found through a path given to a scene command.
+type matchedSceneNode struct {
+	sceneNode
+	path      string
+	ancestors []uint32
+}
+
+// findSceneNodes returns the nodes in the game's current scene that match the path given, erroring if none
+// match. Paths starting with a slash are matched from the root; other paths match the end of nodes' paths.
+func (display *Display) findSceneNodes(pattern string) ([]matchedSceneNode, error) {
+
+	res, err := display.sendRequest(newSceneRefreshPacket())
+	if err != nil {
+		return nil, err
+	}
+
+	tree := res.(*sceneRefreshPacket).SceneTree
+
+	absolute := strings.HasPrefix(pattern, "/")
+	patternNames := strings.Split(strings.Trim(pattern, "/"), "/")
+
+	matches := []matchedSceneNode{}
+
+	var loop func(node sceneNode, names []string, ancestors []uint32) error
+
+	loop = func(node sceneNode, names []string, ancestors []uint32) error {
+
+		names = append(names, node.Name)
+
+		matched := len(names) == len(patternNames) || (!absolute && len(names) > len(patternNames))
+
+		if matched {
+			// Compare the end of the node's path to the pattern.
+			for i, patternName := range patternNames {
+				ok, err := path.Match(patternName, names[len(names)-len(patternNames)+i])
+				if err != nil {
+					return err
+				}
+				if !ok {
+					matched = false
+					break
+				}
+			}
+		}
+
+		if matched {
+			matches = append(matches, matchedSceneNode{
+				sceneNode: node,
+				path:      "/" + strings.Join(names, "/"),
+				ancestors: append([]uint32{}, ancestors...),
+			})
+		}
+
+		ancestors = append(ancestors, node.NodeID)
+
+		for _, child := range node.Children {
+			if err := loop(child, names, ancestors); err != nil {
+				return err
+			}
+		}
+
+		return nil
+
+	}
+
+	if err := loop(tree, []string{}, []uint32{}); err != nil {
+		return nil, err
+	}
+
+	if len(matches) == 0 {
+		return nil, errors.New("no nodes found matching " + strconv.Quote(pattern))
+	}
+
+	return matches, nil
+
+}
+
+// topLevelSceneNodes returns the nodes given, without any that are descendants of others in the list.
+func topLevelSceneNodes(nodes []matchedSceneNode) []matchedSceneNode {
+
+	matched := map[uint32]bool{}
+	for _, node := range nodes {
+		matched[node.NodeID] = true
+	}
+
+	out := []matchedSceneNode{}
+
+	for _, node := range nodes {
+
+		descendant := false
+
+		for _, id := range node.ancestors {
+			if matched[id] {
+				descendant = true
+				break
+			}
+		}
+
+		if !descendant {
+			out = append(out, node)
+		}
+
+	}
+
+	return out
+
+}
+
+// selectSceneNode selects the node given in the game, as well as in the node tree.
+func (display *Display) selectSceneNode(node matchedSceneNode) error {
+
+	res, err := display.sendRequest(newNodeSelectPacket(node.NodeID))
+	if err != nil {
+		return err
+	}
+
+	if errText := res.(*nodeSelectPacket).Error; errText != "" {
+		return errors.New(errText)
+	}
+
+	display.App.QueueUpdate(func() {
+		display.showTreeNode(node.NodeID, node.ancestors)
+	})
+
+	return nil
+
+}
+
+// showTreeNode makes the node with the given ID current in the node tree, expanding the ancestors given so
+// it's visible. This should be called from the UI goroutine.
+func (display *Display) showTreeNode(nodeID uint32, ancestors []uint32) {
+
+	treeNode, exists := display.SceneNodesToTreeNodes[nodeID]
+	if !exists {
+		return
+	}
+
+	for _, id := range ancestors {
+		if ancestor, exists := display.SceneNodesToTreeNodes[id]; exists {
+			ancestor.SetExpanded(true)
+		}
+	}
+
+	display.TreeView.SetCurrentNode(treeNode)
+	display.TreeViewScroll.ScrollTo(display.TreeViewScroll.ChildIndexInTree(treeNode))
+
+}
+
+// sceneTreeAncestors returns the IDs of the ancestors of the node with the given ID in the tree, starting
+// from the root, or nil if the node isn't in the tree.
+func sceneTreeAncestors(tree sceneNode, nodeID uint32) []uint32 {
+
+	if tree.NodeID == nodeID {
+		return []uint32{}
+	}
+
+	for _, child := range tree.Children {
+		if ancestors := sceneTreeAncestors(child, nodeID); ancestors != nil {
+			return append([]uint32{tree.NodeID}, ancestors...)
+		}
+	}
+
+	return nil
+
+}
+
+// deleteSelectedNode deletes the selected node, updating the node tree afterwards.
+func (display *Display) deleteSelectedNode() error {
+
+	res, err := display.sendRequest(newNodeDeletePacket())
+	if err != nil {
+		return err
+	}
+
+	deleted := res.(*nodeDeletePacket)
+	if deleted.SceneTree.Name == "" {
+		return errors.New("the scene's root node can't be deleted")
+	}
+
+	display.updateSceneTree(deleted.SceneTree, deleted.NewSelectedNode)
+
+	return nil
+
+}
+
+// updateSceneTree sets the scene tree received after altering the scene's hierarchy, selecting the node with
+// the ID given in the node tree once it's refreshed.
+func (display *Display) updateSceneTree(tree sceneNode, selectedNode uint32) {
+	display.App.QueueUpdate(func() {
+		display.SelectNextNode = true
+		display.currentSceneTree = tree
+		display.SelectNextNodeIndex = selectedNode
+	})
+}
+
+// setSceneNodesVisible sets the visibility of the nodes given.
+func (display *Display) setSceneNodesVisible(nodes []matchedSceneNode, visible bool) error {
+
+	for _, node := range nodes {
+
+		res, err := display.sendRequest(newNodeSetVisiblePacket(node.NodeID, visible))
+		if err != nil {
+			return err
+		}
+
+		if errText := res.(*nodeSetVisiblePacket).Error; errText != "" {
+			return errors.New(errText)
+		}
+
+	}
+
+	return nil
+
+}
+
+func (display *Display) runVisibilityCommand(args []string, visible bool) (string, error) {
+
+	verb := "hide"
+	if visible {
+		verb = "show"
+	}
+
+	if len(args) != 1 {
+		return "", errors.New("usage: " + verb + " <nodes>")
+	}
+
+	nodes, err := display.findSceneNodes(args[0])
+	if err != nil {
+		return "", err
+	}
+
+	if err := display.setSceneNodesVisible(nodes, visible); err != nil {
+		return "", err
+	}
+
+	if visible {
+		return "showed " + describeSceneNodes(nodes), nil
+	}
+
+	return "hid " + describeSceneNodes(nodes), nil
+
+}
+
+// describeSceneNodes returns the path of the node given, or the number of nodes if there's more than one.
+func describeSceneNodes(nodes []matchedSceneNode) string {
+	if len(nodes) == 1 {
+		return nodes[0].path
+	}
+	return strconv.Itoa(len(nodes)) + " nodes"
+}
+
+// parseCommandVector parses three command arguments into a vector.
+func parseCommandVector(args []string) (tetra3d.Vector3, error) {
+
+	if len(args) != 3 {
+		return tetra3d.Vector3{}, errors.New("expected three values (x y z)")
+	}
+
+	values := [3]float32{}
+
+	for i, arg := range args {
+		value, err := strconv.ParseFloat(arg, 32)
+		if err != nil {
+			return tetra3d.Vector3{}, err
+		}
+		values[i] = float32(value)
+	}
+
+	return tetra3d.Vector3{X: values[0], Y: values[1], Z: values[2]}, nil
+
+}
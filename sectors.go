@@ -0,0 +1,352 @@
+package tetraterm
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/rivo/tview"
+	"github.com/solarlune/tetra3d"
+	"github.com/solarlune/tetra3d/colors"
+)
+
+const (
+	sectorQuery = iota
+	sectorToggleRendering
+	sectorSetDepth
+	sectorToggleOverlay
+)
+
+// sectorInfo describes a sector for the Sectors pane; Depth is how many sectors away from the camera's current
+// sector it is.
+type sectorInfo struct {
+	Name   string
+	NodeID uint32
+	Depth  int
+}
+
+// sectorModels returns the models in the tree starting with the root given that form sectors.
+func sectorModels(root tetra3d.INode) []*tetra3d.Model {
+
+	models := []*tetra3d.Model{}
+
+	for _, node := range root.SearchTree().INodes() {
+		if model, ok := node.(*tetra3d.Model); ok {
+			if sector := model.Sector(); sector != nil && sector.Model == model {
+				models = append(models, model)
+			}
+		}
+	}
+
+	return models
+
+}
+
+// sectorDepths returns how many sectors away from the sector given each of its neighbors within the depth given
+// are, including the sector itself at a depth of 0.
+func sectorDepths(sector *tetra3d.Sector, depth int) map[*tetra3d.Sector]int {
+
+	depths := map[*tetra3d.Sector]int{sector: 0}
+	next := []*tetra3d.Sector{sector}
+
+	for d := 1; d <= depth; d++ {
+
+		current := next
+		next = nil
+
+		for _, s := range current {
+			for neighbor := range s.Neighbors {
+				if _, exists := depths[neighbor]; !exists {
+					depths[neighbor] = d
+					next = append(next, neighbor)
+				}
+			}
+		}
+
+	}
+
+	return depths
+
+}
+
+// handleSectors applies the sector action given in the packet to the camera last passed to Server.Draw(),
+// filling out the packet with the resulting state. This should be run on the game thread.
+func (server *Server) handleSectors(packet *sectorPacket) error {
+
+	camera := server.t3dCamera
+
+	if camera == nil {
+		return errors.New("no camera has been passed to Server.Draw() yet")
+	}
+
+	switch packet.Action {
+
+	case sectorToggleRendering:
+		camera.SectorRendering = !camera.SectorRendering
+
+	case sectorSetDepth:
+		if packet.RenderDepth < 0 {
+			return errors.New("the sector render depth can't be negative")
+		}
+		camera.SectorRenderDepth = packet.RenderDepth
+
+	case sectorToggleOverlay:
+		server.DebugDrawSectors = !server.DebugDrawSectors
+
+	}
+
+	packet.SectorRendering = camera.SectorRendering
+	packet.RenderDepth = camera.SectorRenderDepth
+	packet.DrawOverlay = server.DebugDrawSectors
+	packet.Neighbors = []sectorInfo{}
+
+	if server.activeScene != nil {
+		packet.SectorCount = len(sectorModels(server.activeScene.Root))
+	}
+
+	if sector := camera.CurrentSector(); sector != nil {
+
+		packet.HasCurrent = true
+		packet.Current = sectorInfo{Name: sector.Model.Name(), NodeID: sector.Model.ID()}
+
+		for neighbor, depth := range sectorDepths(sector, camera.SectorRenderDepth) {
+			if neighbor != sector {
+				packet.Neighbors = append(packet.Neighbors, sectorInfo{Name: neighbor.Model.Name(), NodeID: neighbor.Model.ID(), Depth: depth})
+			}
+		}
+
+		sort.Slice(packet.Neighbors, func(i, j int) bool {
+			if packet.Neighbors[i].Depth != packet.Neighbors[j].Depth {
+				return packet.Neighbors[i].Depth < packet.Neighbors[j].Depth
+			}
+			return packet.Neighbors[i].Name < packet.Neighbors[j].Name
+		})
+
+	}
+
+	return nil
+
+}
+
+// drawSectors draws the wireframes of the scene's sectors, with the camera's current sector in green, the
+// neighbors it renders in sky blue, and the rest in dark gray.
+func (server *Server) drawSectors(screen *ebiten.Image, camera *tetra3d.Camera) {
+
+	depths := map[*tetra3d.Sector]int{}
+
+	if current := camera.CurrentSector(); current != nil {
+		depths = sectorDepths(current, camera.SectorRenderDepth)
+	}
+
+	for _, model := range sectorModels(server.activeScene.Root) {
+
+		color := colors.DarkGray()
+
+		if depth, exists := depths[model.Sector()]; exists {
+			if depth == 0 {
+				color = colors.Green()
+			} else {
+				color = colors.SkyBlue()
+			}
+		}
+
+		camera.DrawDebugWireframe(screen, model, color)
+
+	}
+
+}
+
+// initSectorPane creates the Sectors tool pane, which shows the camera's current sector and the neighbors it
+// renders, and controls sector rendering.
+func (display *Display) initSectorPane() {
+
+	list := tview.NewList()
+	list.SetBackgroundColor(tcell.ColorDefault)
+	list.SetMainTextColor(tcell.ColorWhite)
+	list.ShowSecondaryText(false)
+
+	status := tview.NewTextView()
+	status.SetDynamicColors(true)
+	status.SetWrap(true)
+	status.SetBackgroundColor(tcell.ColorDefault)
+
+	pane := tview.NewFlex()
+	pane.SetDirection(tview.FlexRow)
+	pane.SetBorder(true)
+	pane.SetTitle("[ Sectors ]")
+	pane.AddItem(list, 0, 1, true)
+	pane.AddItem(status, 2, 0, false)
+
+	display.addToolPane("sectors", tcell.KeyF13, pane)
+
+	help := "[gray]Enter / Click: Toggle or Select Sector's Model, +/-: Change Render Depth[-]"
+	status.SetText(help)
+
+	// The number of list items before the sectors themselves.
+	const settingItems = 3
+
+	state := newSectorPacket(sectorQuery)
+	sectors := []sectorInfo{}
+
+	refresh := func() {
+
+		current := list.GetCurrentItem()
+
+		list.Clear()
+
+		onOff := func(on bool) string {
+			if on {
+				return "[green]On[-]"
+			}
+			return "[gray]Off[-]"
+		}
+
+		list.AddItem("Sector Rendering: "+onOff(state.SectorRendering), "", 0, nil)
+		list.AddItem("Render Depth: "+strconv.Itoa(state.RenderDepth), "", 0, nil)
+		list.AddItem("Sector Overlay: "+onOff(state.DrawOverlay)+" [gray](current: [green]green[gray], rendered neighbors: [skyblue]blue[gray])[-]", "", 0, nil)
+
+		sectors = []sectorInfo{}
+
+		if state.HasCurrent {
+			sectors = append(sectors, state.Current)
+			sectors = append(sectors, state.Neighbors...)
+			list.AddItem("[green]● "+tview.Escape(state.Current.Name)+"[-] [gray](current sector)[-]", "", 0, nil)
+			for _, n := range state.Neighbors {
+				list.AddItem(fmt.Sprintf("[skyblue]○[-] %s [gray](%d away)[-]", tview.Escape(n.Name), n.Depth), "", 0, nil)
+			}
+		} else if state.SectorCount == 0 {
+			list.AddItem("[gray]The scene has no sectors.[-]", "", 0, nil)
+		} else {
+			list.AddItem(fmt.Sprintf("[gray]The camera isn't in any of the scene's %d sectors.[-]", state.SectorCount), "", 0, nil)
+		}
+
+		if current < list.GetItemCount() {
+			list.SetCurrentItem(current)
+		}
+
+	}
+
+	send := func(packet *sectorPacket) {
+		go func() {
+
+			res, err := display.sendRequest(packet)
+
+			display.App.QueueUpdate(func() {
+				if err != nil {
+					status.SetText("[red]" + tview.Escape(err.Error()) + "[-]\n" + help)
+					return
+				}
+				if errText := res.(*sectorPacket).Error; errText != "" {
+					status.SetText("[red]" + tview.Escape(errText) + "[-]\n" + help)
+					return
+				}
+				state = res.(*sectorPacket)
+				refresh()
+			})
+
+		}()
+	}
+
+	setDepth := func(depth int) {
+		packet := newSectorPacket(sectorSetDepth)
+		packet.RenderDepth = depth
+		send(packet)
+	}
+
+	list.SetSelectedFunc(func(index int, mainText, secondaryText string, shortcut rune) {
+
+		switch index {
+
+		case 0:
+			send(newSectorPacket(sectorToggleRendering))
+
+		case 1:
+			display.promptValue("Sector Render Depth", strconv.Itoa(state.RenderDepth), func(text string) error {
+				depth, err := strconv.Atoi(text)
+				if err != nil {
+					return errors.New("the render depth must be a whole number")
+				}
+				setDepth(depth)
+				return nil
+			})
+
+		case 2:
+			send(newSectorPacket(sectorToggleOverlay))
+
+		default:
+			if i := index - settingItems; i < len(sectors) {
+				sector := sectors[i]
+				node := matchedSceneNode{sceneNode: sceneNode{NodeID: sector.NodeID}, ancestors: sceneTreeAncestors(display.currentSceneTree, sector.NodeID)}
+				go func() {
+					if err := display.selectSceneNode(node); err != nil {
+						display.App.QueueUpdate(func() { status.SetText("[red]" + tview.Escape(err.Error()) + "[-]\n" + help) })
+					}
+				}()
+			}
+
+		}
+
+	})
+
+	list.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+
+		switch event.Rune() {
+
+		case '+', '=':
+			setDepth(state.RenderDepth + 1)
+			return nil
+
+		case '-', '_':
+			if state.RenderDepth > 0 {
+				setDepth(state.RenderDepth - 1)
+			}
+			return nil
+
+		}
+
+		return event
+
+	})
+
+	refresh()
+
+	go func() {
+
+		layout := ""
+
+		for {
+
+			time.Sleep(time.Millisecond * 250)
+
+			if !display.running.Load() {
+				return
+			}
+
+			if !display.toolPaneVisible("sectors") {
+				continue
+			}
+
+			res, err := display.sendRequest(newSectorPacket(sectorQuery))
+			if err != nil || res.(*sectorPacket).Error != "" {
+				continue
+			}
+
+			packet := res.(*sectorPacket)
+
+			if newLayout := fmt.Sprint(*packet); newLayout != layout {
+				layout = newLayout
+				display.App.QueueUpdate(func() {
+					state = packet
+					refresh()
+				})
+			}
+
+		}
+
+	}()
+
+}
@@ -2,6 +2,9 @@ package main
 
 import (
 	"flag"
+	"fmt"
+	"os"
+	"strings"
 
 	"github.com/solarlune/tetraterm"
 )
@@ -10,8 +13,52 @@ func main() {
 
 	hostName := flag.String("host", "", "Defines the host for TetraTerm to listen to. A blank string means localhost (this machine).")
 	portNumber := flag.String("port", "7979", "Defines the port for TetraTerm to listen on. This should be the same as the server in your game.")
+	profileDir := flag.String("profiledir", "", "Defines the directory that profiles captured from the game are saved to. A blank string means the current directory.")
+	watch := flag.String("watch", "", "In run mode, defines a comma-separated list of directories to watch for changes to Go source and asset files, restarting the game when they change (i.e. ./...).")
+	crashDir := flag.String("crashdir", "", "Defines the directory that crash reports received from the game are saved to. A blank string means the current directory.")
+	recordDir := flag.String("recorddir", "", "Defines the directory that input recordings captured from the game are saved to. A blank string means the current directory.")
 
-	flag.Parse()
+	flag.Usage = func() {
+		fmt.Fprintln(flag.CommandLine.Output(), "Usage:")
+		fmt.Fprintln(flag.CommandLine.Output(), "  tetraterm [flags]")
+		fmt.Fprintln(flag.CommandLine.Output(), "  tetraterm run [flags] -- <command to start the game>   (i.e. tetraterm run --watch ./... -- go run ./mygame)")
+		fmt.Fprintln(flag.CommandLine.Output(), "\nFlags:")
+		flag.PrintDefaults()
+	}
+
+	args := os.Args[1:]
+
+	// In run mode, everything after "--" is the command used to start the game.
+	var gameCommand []string
+
+	if len(args) > 0 && args[0] == "run" {
+
+		args = args[1:]
+
+		for i, arg := range args {
+			if arg == "--" {
+				gameCommand = args[i+1:]
+				args = args[:i]
+				break
+			}
+		}
+
+		if len(gameCommand) == 0 {
+			fmt.Fprintln(flag.CommandLine.Output(), "No command given to start the game with.")
+			flag.Usage()
+			os.Exit(2)
+		}
+
+	}
+
+	flag.CommandLine.Parse(args)
+
+	// Watching restarts the game, which the terminal can only do if it started it.
+	if *watch != "" && gameCommand == nil {
+		fmt.Fprintln(flag.CommandLine.Output(), "--watch can only be used in run mode (i.e. tetraterm run --watch ./... -- go run ./mygame).")
+		flag.Usage()
+		os.Exit(2)
+	}
 
 	settings := tetraterm.NewDefaultConnectionSettings()
 
@@ -19,6 +66,20 @@ func main() {
 	settings.Port = *portNumber
 
 	tapp := tetraterm.NewDisplay(settings)
+	tapp.ProfileDirectory = *profileDir
+	tapp.CrashDirectory = *crashDir
+	tapp.RecordingDirectory = *recordDir
+
+	if gameCommand != nil {
+		if err := tapp.StartGameProcess(gameCommand); err != nil {
+			panic(err)
+		}
+		if *watch != "" {
+			if err := tapp.WatchGameSources(strings.Split(*watch, ",")); err != nil {
+				panic(err)
+			}
+		}
+	}
 
 	err := tapp.Start()
 
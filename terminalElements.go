@@ -262,3 +262,190 @@ func (sb *Scrollbar) ChildIndexInTree(treeNode *tview.TreeNode) int {
 	return -1
 
 }
+
+// newCenteredPrimitive returns a Flex that centers the given primitive on the screen with the given size,
+// suitable for adding as a page on top of the main view.
+func newCenteredPrimitive(primitive tview.Primitive, width, height int) tview.Primitive {
+
+	row := tview.NewFlex()
+	row.SetDirection(tview.FlexRow)
+	row.AddItem(nil, 0, 1, false)
+	row.AddItem(primitive, height, 0, true)
+	row.AddItem(nil, 0, 1, false)
+
+	column := tview.NewFlex()
+	column.AddItem(nil, 0, 1, false)
+	column.AddItem(row, width, 0, true)
+	column.AddItem(nil, 0, 1, false)
+
+	return column
+
+}
+
+// valueTreeView displays trees of serialized Go values, keeping track of which branches are expanded
+// and which value is selected as the values are updated.
+type valueTreeView struct {
+	*tview.TreeView
+	expanded map[string]bool
+
+	// EditFunc, if set, is called when an editable value is selected, with the top-level value it belongs to
+	// and the path of names leading to it from that top-level value (see resolveValuePath()).
+	EditFunc func(root valueNode, path []string, value valueNode)
+}
+
+type valueTreeItem struct {
+	key   string
+	root  valueNode
+	path  []string
+	value valueNode
+}
+
+func newValueTreeView() *valueTreeView {
+
+	view := &valueTreeView{
+		TreeView: tview.NewTreeView(),
+		expanded: map[string]bool{},
+	}
+
+	view.SetBackgroundColor(tcell.ColorDefault)
+	view.SetGraphicsColor(tcell.ColorGreen)
+	view.SetTopLevel(1)
+	view.SetRoot(tview.NewTreeNode(""))
+
+	view.SetSelectedFunc(func(node *tview.TreeNode) {
+		item, ok := node.GetReference().(*valueTreeItem)
+		if !ok {
+			return
+		}
+		if len(node.GetChildren()) > 0 {
+			node.SetExpanded(!node.IsExpanded())
+			view.expanded[item.key] = node.IsExpanded()
+		} else if item.value.Editable && view.EditFunc != nil {
+			view.EditFunc(item.root, item.path, item.value)
+		}
+	})
+
+	return view
+
+}
+
+// SetValues replaces the values displayed in the tree.
+func (view *valueTreeView) SetValues(values []valueNode) {
+
+	currentKey := ""
+	if current := view.GetCurrentNode(); current != nil {
+		if item, ok := current.GetReference().(*valueTreeItem); ok {
+			currentKey = item.key
+		}
+	}
+
+	var newCurrent *tview.TreeNode
+
+	var build func(root, value valueNode, parentKey string, path []string) *tview.TreeNode
+
+	build = func(root, value valueNode, parentKey string, path []string) *tview.TreeNode {
+
+		item := &valueTreeItem{
+			key:   parentKey + "\x00" + value.Name,
+			root:  root,
+			path:  path,
+			value: value,
+		}
+
+		text := value.String()
+		if value.Editable && view.EditFunc != nil {
+			text += " ✎"
+		}
+
+		treeNode := tview.NewTreeNode(tview.Escape(text))
+		treeNode.SetReference(item)
+		treeNode.SetSelectable(true)
+		treeNode.SetExpanded(view.expanded[item.key])
+
+		if len(value.Children) > 0 {
+			treeNode.SetColor(tcell.ColorSkyblue)
+		} else if value.Editable && view.EditFunc != nil {
+			treeNode.SetColor(tcell.ColorYellow)
+		}
+
+		for _, child := range value.Children {
+			childPath := append(append([]string{}, path...), child.Name)
+			treeNode.AddChild(build(root, child, item.key, childPath))
+		}
+
+		if item.key == currentKey {
+			newCurrent = treeNode
+		}
+
+		return treeNode
+
+	}
+
+	root := tview.NewTreeNode("")
+	for _, value := range values {
+		// The paths of values are relative to each top-level value, so they don't include its name.
+		root.AddChild(build(value, value, "", []string{}))
+	}
+
+	view.SetRoot(root)
+
+	if newCurrent != nil {
+		view.SetCurrentNode(newCurrent)
+	} else if len(root.GetChildren()) > 0 {
+		view.SetCurrentNode(root.GetChildren()[0])
+	}
+
+}
+
+// promptValue shows a small page prompting the user to enter a value, prefilled with the initial text given.
+// When Enter is pressed, submit is called with the entered text; if it returns an error, the error is shown
+// and the prompt stays open. Escape cancels the prompt.
+func (display *Display) promptValue(title, initial string, submit func(text string) error) {
+
+	previousFocus := display.App.GetFocus()
+
+	status := tview.NewTextView()
+	status.SetDynamicColors(true)
+	status.SetBackgroundColor(tcell.ColorDefault)
+	status.SetText("Enter: Apply, Esc: Cancel")
+
+	field := tview.NewInputField()
+	field.SetText(initial)
+	field.SetFieldBackgroundColor(tcell.ColorDarkSlateGray)
+	field.SetFieldTextColor(tcell.ColorLightBlue)
+	field.SetBackgroundColor(tcell.ColorDefault)
+
+	close := func() {
+		display.Root.RemovePage("prompt")
+		display.App.SetFocus(previousFocus)
+	}
+
+	field.SetDoneFunc(func(key tcell.Key) {
+
+		if key == tcell.KeyEscape {
+			close()
+			return
+		}
+
+		if key == tcell.KeyEnter {
+			if err := submit(field.GetText()); err != nil {
+				status.SetText("[red]" + tview.Escape(err.Error()))
+			} else {
+				close()
+			}
+		}
+
+	})
+
+	layout := tview.NewFlex()
+	layout.SetDirection(tview.FlexRow)
+	layout.SetBorder(true)
+	layout.SetTitle("[ " + tview.Escape(title) + " ]")
+	layout.AddItem(field, 1, 0, true)
+	layout.AddItem(status, 0, 1, false)
+
+	display.Root.RemovePage("prompt")
+	display.Root.AddPage("prompt", newCenteredPrimitive(layout, 60, 5), true, true)
+	display.App.SetFocus(field)
+
+}
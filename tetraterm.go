@@ -4,6 +4,7 @@ package tetraterm
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"math/rand"
@@ -11,6 +12,7 @@ import (
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"sync/atomic"
 	"time"
 
@@ -19,9 +21,16 @@ import (
 	p2p "github.com/leprosus/golang-p2p"
 	"github.com/rivo/tview"
 	"github.com/solarlune/tetra3d"
-	"github.com/solarlune/tetra3d/colors"
 )
 
+// How long a request from the terminal waits for the game thread to handle it before giving up; this
+// should be comfortably less than connectionTimeout, so the response still makes it back to the terminal.
+const gameThreadTimeout = time.Millisecond * 200
+
+// The timeout used for the P2P connection between the game and the terminal, and for the game's handling
+// of each request.
+const connectionTimeout = time.Millisecond * 500
+
 type ogLocalTransform struct {
 	Node     tetra3d.INode
 	Parent   tetra3d.INode
@@ -144,10 +153,41 @@ type Server struct {
 	prevScene     *tetra3d.Scene
 	t3dCamera     *tetra3d.Camera
 	selectedNode  tetra3d.INode
+	profile       profileCapture
+	timings       timingScopes
+	metrics       customMetrics
+	watches       watchList
+	tweaks        tweakList
+	nodeData      nodeDataSnapshot
+	actions       actionList
+	commands      commandList
+	logs          logBuffer
+	crash         crashState
+	time          timeControl
+	breakpoints   breakpointList
+	input         virtualInput
+	debugShapes   debugShapes
+	gizmoPick     gizmoPick
+	highlight     selectionHighlight
+	lightStates   lightStates
+
+	tasks      []func()
+	tasksMutex sync.Mutex
 
 	DebugDrawHierarchy bool
 	DebugDrawWireframe bool
 	DebugDrawBounds    bool
+	DebugDrawShapes    bool // Whether shapes drawn through Server.DebugLine() and friends are drawn; defaults to true
+	// Whether gizmos are drawn for lights, paths, and cameras other than the one rendering. While they're drawn,
+	// Ctrl+clicking a gizmo's icon in the game window selects its node.
+	DebugDrawGizmos bool
+	// Whether the scene's sectors are drawn, colored by whether the camera's in them, rendering them as
+	// neighbors, or neither; this is toggled from the terminal's Sectors pane.
+	DebugDrawSectors bool
+
+	// DebugOverlay controls how the debug overlays are drawn; it can be changed from the terminal's Overlay
+	// Options page (Ctrl+D).
+	DebugOverlay OverlaySettings
 }
 
 // NewServer returns a new server, using the connection settings provided. If you pass nil,
@@ -158,7 +198,12 @@ func NewServer(settings *ConnectionSettings) *Server {
 		settings = NewDefaultConnectionSettings()
 	}
 
-	server := &Server{}
+	server := &Server{
+		DebugDrawShapes: true,
+		DebugOverlay:    NewDefaultOverlaySettings(),
+	}
+
+	server.logs.session = time.Now().UnixNano()
 
 	port := p2p.NewTCP(settings.Host, settings.Port)
 
@@ -171,6 +216,11 @@ func NewServer(settings *ConnectionSettings) *Server {
 		s.SetLogger(emptyLogger{})
 	}
 
+	serverSettings := p2p.NewServerSettings()
+	serverSettings.SetConnTimeout(connectionTimeout)
+	serverSettings.SetHandleTimeout(connectionTimeout)
+	s.SetSettings(serverSettings)
+
 	server.P2PServer = s
 
 	s.SetHandle(ptNodeFollowCamera, func(ctx context.Context, req p2p.Data) (res p2p.Data, err error) {
@@ -228,6 +278,32 @@ func NewServer(settings *ConnectionSettings) *Server {
 
 	})
 
+	s.SetHandle(ptToggleDebugDrawShapes, func(ctx context.Context, req p2p.Data) (res p2p.Data, err error) {
+
+		server.DebugDrawShapes = !server.DebugDrawShapes
+
+		packet := toggleDebugDrawShapes{}
+		packet.Decode(req)
+		packet.DebugDrawOn = server.DebugDrawShapes
+		res = packet.Encode()
+
+		return
+
+	})
+
+	s.SetHandle(ptToggleDebugDrawGizmos, func(ctx context.Context, req p2p.Data) (res p2p.Data, err error) {
+
+		server.DebugDrawGizmos = !server.DebugDrawGizmos
+
+		packet := toggleDebugDrawGizmos{}
+		packet.Decode(req)
+		packet.DebugDrawOn = server.DebugDrawGizmos
+		res = packet.Encode()
+
+		return
+
+	})
+
 	s.SetHandle(ptNodeSelect, func(ctx context.Context, req p2p.Data) (res p2p.Data, err error) {
 
 		packet := &nodeSelectPacket{}
@@ -236,12 +312,15 @@ func NewServer(settings *ConnectionSettings) *Server {
 			panic(err)
 		}
 
+		found := false
+
 		if server.activeScene != nil {
 
 			for _, node := range server.activeScene.Root.SearchTree().INodes() {
 
 				if node.ID() == packet.NodeID {
 					server.selectedNode = node
+					found = true
 					break
 				}
 
@@ -249,34 +328,44 @@ func NewServer(settings *ConnectionSettings) *Server {
 
 		}
 
+		// The previous selection is kept, so the terminal needs to know when the node wasn't found to avoid
+		// operating on the wrong node.
+		if !found {
+			packet.Error = "no node with ID " + strconv.Itoa(int(packet.NodeID)) + " in the scene"
+		}
+
+		res = packet.Encode()
+
 		return
 
 	})
 
 	s.SetHandle(ptNodeMove, func(ctx context.Context, req p2p.Data) (res p2p.Data, err error) {
 
-		if server.selectedNode != nil {
+		packet := &nodeMovePacket{}
+		packet.Decode(req)
 
-			packet := &nodeMovePacket{}
-			packet.Decode(req)
+		if server.selectedNode != nil {
 			server.selectedNode.Move(packet.X, packet.Y, packet.Z)
-
 		}
 
+		res = packet.Encode()
+
 		return
 
 	})
 
 	s.SetHandle(ptNodeRotate, func(ctx context.Context, req p2p.Data) (res p2p.Data, err error) {
 
-		if server.selectedNode != nil {
+		packet := &nodeRotatePacket{}
+		packet.Decode(req)
 
-			packet := &nodeRotatePacket{}
-			packet.Decode(req)
+		if server.selectedNode != nil {
 			server.selectedNode.Rotate(packet.X, packet.Y, packet.Z, packet.Angle)
-
 		}
 
+		res = packet.Encode()
+
 		return
 
 	})
@@ -284,6 +373,41 @@ func NewServer(settings *ConnectionSettings) *Server {
 	s.SetHandle(ptNodeReset, func(ctx context.Context, req p2p.Data) (res p2p.Data, err error) {
 
 		server.resetSelectedNode()
+		res = newNodeResetPacket().Encode()
+		return
+
+	})
+
+	s.SetHandle(ptNodeSetVisible, func(ctx context.Context, req p2p.Data) (res p2p.Data, err error) {
+
+		packet := &nodeSetVisiblePacket{}
+		err = packet.Decode(req)
+		if err != nil {
+			return
+		}
+
+		setErr := server.runOnGameThreadAndWait(func() error {
+
+			if server.activeScene == nil {
+				return errors.New("no scene set for server")
+			}
+
+			for _, node := range server.activeScene.Root.SearchTree().INodes() {
+				if node.ID() == packet.NodeID {
+					node.SetVisible(packet.Visible, false)
+					return nil
+				}
+			}
+
+			return errors.New("no node with ID " + strconv.Itoa(int(packet.NodeID)))
+
+		})
+
+		if setErr != nil {
+			packet.Error = setErr.Error()
+		}
+
+		res = packet.Encode()
 		return
 
 	})
@@ -299,6 +423,7 @@ func NewServer(settings *ConnectionSettings) *Server {
 			packet.Rotation = matrix4ToMatrix3(server.selectedNode.LocalRotation())
 			packet.Visible = server.selectedNode.IsVisible()
 			packet.Type = server.selectedNode.Type()
+			packet.Data, packet.HasData, packet.Inspectors = server.nodeData.latest(packet.ID)
 			res = packet.Encode()
 
 		}
@@ -315,8 +440,17 @@ func NewServer(settings *ConnectionSettings) *Server {
 			FPS: float32(ebiten.ActualFPS()),
 			TPS: float32(ebiten.ActualTPS()),
 			// ModelCount: server.activeScene.Root.ChildrenRecursive().ByType(tetra3d.NodeTypeModel).,
+			Metrics: server.metrics.info(),
 		}
 
+		packet.PickedNode, packet.HasPickedNode = server.gizmoPick.take()
+
+		timeState := newTimeControlPacket(timeControlQuery)
+		server.handleTimeControl(timeState)
+		packet.Paused = timeState.Paused
+		packet.Steps = timeState.Steps
+		packet.TimeScale = timeState.Scale
+
 		if server.t3dCamera != nil {
 			packet.DebugInfo = server.t3dCamera.DebugInfo
 
@@ -386,7 +520,16 @@ func NewServer(settings *ConnectionSettings) *Server {
 					if node.Name() == packet.NodeToCreate {
 
 						clone := node.Clone()
-						server.activeScene.Root.AddChildren(clone)
+						var parent tetra3d.INode = server.activeScene.Root
+						if packet.HasParent {
+							for _, candidate := range server.activeScene.Root.SearchTree().INodes() {
+								if candidate.ID() == packet.Parent {
+									parent = candidate
+									break
+								}
+							}
+						}
+						parent.AddChildren(clone)
 						packet.NewSelectedNode = clone.ID()
 						server.selectedNode = clone
 						packet.SceneTree = constructNodeTree(server.activeScene.Root)
@@ -447,7 +590,10 @@ func NewServer(settings *ConnectionSettings) *Server {
 			var newSelection tetra3d.INode
 
 			if len(parent.Children()) > 0 {
-				newSelection = parent.Children()[ogIndex-1]
+				if ogIndex > 0 {
+					ogIndex--
+				}
+				newSelection = parent.Children()[ogIndex]
 			} else {
 				newSelection = parent
 			}
@@ -500,6 +646,453 @@ func NewServer(settings *ConnectionSettings) *Server {
 
 	})
 
+	s.SetHandle(ptProfileStart, func(ctx context.Context, req p2p.Data) (res p2p.Data, err error) {
+
+		packet := &profileStartPacket{}
+		err = packet.Decode(req)
+		if err != nil {
+			return
+		}
+
+		if profileErr := server.startProfile(packet.Kind, packet.Duration); profileErr != nil {
+			packet.Error = profileErr.Error()
+		}
+
+		res = packet.Encode()
+		return
+
+	})
+
+	s.SetHandle(ptProfileFetch, func(ctx context.Context, req p2p.Data) (res p2p.Data, err error) {
+
+		packet := &profileFetchPacket{}
+		err = packet.Decode(req)
+		if err != nil {
+			return
+		}
+
+		server.fetchProfile(packet)
+		res = packet.Encode()
+		return
+
+	})
+
+	s.SetHandle(ptTimingScopes, func(ctx context.Context, req p2p.Data) (res p2p.Data, err error) {
+
+		packet := &timingScopesPacket{
+			Scopes: server.timings.info(),
+		}
+
+		res = packet.Encode()
+		return
+
+	})
+
+	s.SetHandle(ptWatches, func(ctx context.Context, req p2p.Data) (res p2p.Data, err error) {
+
+		packet := &watchesPacket{
+			Watches: server.watches.latest(),
+		}
+
+		res = packet.Encode()
+		return
+
+	})
+
+	s.SetHandle(ptTweaks, func(ctx context.Context, req p2p.Data) (res p2p.Data, err error) {
+
+		packet := &tweaksPacket{
+			Tweaks: server.tweaks.latest(),
+		}
+
+		res = packet.Encode()
+		return
+
+	})
+
+	s.SetHandle(ptTweakSet, func(ctx context.Context, req p2p.Data) (res p2p.Data, err error) {
+
+		packet := &tweakSetPacket{}
+		err = packet.Decode(req)
+		if err != nil {
+			return
+		}
+
+		apply, setErr := server.tweaks.set(packet.Name, packet.Value)
+		if setErr != nil {
+			packet.Error = setErr.Error()
+		} else {
+			server.runOnGameThread(apply)
+		}
+
+		res = packet.Encode()
+		return
+
+	})
+
+	s.SetHandle(ptNodeDataSet, func(ctx context.Context, req p2p.Data) (res p2p.Data, err error) {
+
+		packet := &nodeDataSetPacket{}
+		err = packet.Decode(req)
+		if err != nil {
+			return
+		}
+
+		if setErr := server.setSelectedNodeData(packet.NodeID, packet.Path, packet.Value); setErr != nil {
+			packet.Error = setErr.Error()
+		}
+
+		res = packet.Encode()
+		return
+
+	})
+
+	s.SetHandle(ptNodeInspectorSet, func(ctx context.Context, req p2p.Data) (res p2p.Data, err error) {
+
+		packet := &nodeInspectorSetPacket{}
+		err = packet.Decode(req)
+		if err != nil {
+			return
+		}
+
+		if setErr := server.setSelectedNodeInspectorField(packet.NodeID, packet.Section, packet.Field, packet.Value); setErr != nil {
+			packet.Error = setErr.Error()
+		}
+
+		res = packet.Encode()
+		return
+
+	})
+
+	s.SetHandle(ptActions, func(ctx context.Context, req p2p.Data) (res p2p.Data, err error) {
+
+		packet := &actionsPacket{
+			Actions: server.actions.info(),
+		}
+
+		res = packet.Encode()
+		return
+
+	})
+
+	s.SetHandle(ptActionRun, func(ctx context.Context, req p2p.Data) (res p2p.Data, err error) {
+
+		packet := &actionRunPacket{}
+		err = packet.Decode(req)
+		if err != nil {
+			return
+		}
+
+		if runErr := server.runAction(packet.Name); runErr != nil {
+			packet.Error = runErr.Error()
+		}
+
+		res = packet.Encode()
+		return
+
+	})
+
+	s.SetHandle(ptCommands, func(ctx context.Context, req p2p.Data) (res p2p.Data, err error) {
+
+		packet := &commandsPacket{
+			Commands: server.commands.info(),
+		}
+
+		res = packet.Encode()
+		return
+
+	})
+
+	s.SetHandle(ptCommandRun, func(ctx context.Context, req p2p.Data) (res p2p.Data, err error) {
+
+		packet := &commandRunPacket{}
+		err = packet.Decode(req)
+		if err != nil {
+			return
+		}
+
+		output, runErr := server.runCommand(packet.Line)
+		packet.Output = output
+		if runErr != nil {
+			packet.Error = runErr.Error()
+		}
+
+		res = packet.Encode()
+		return
+
+	})
+
+	s.SetHandle(ptLogs, func(ctx context.Context, req p2p.Data) (res p2p.Data, err error) {
+
+		packet := &logsPacket{}
+		err = packet.Decode(req)
+		if err != nil {
+			return
+		}
+
+		packet.Records = server.logs.since(packet.After)
+		packet.Session = server.logs.session
+
+		res = packet.Encode()
+		return
+
+	})
+
+	s.SetHandle(ptCrash, func(ctx context.Context, req p2p.Data) (res p2p.Data, err error) {
+
+		packet := &crashPacket{}
+		packet.Report, packet.Crashed = server.crash.take()
+
+		res = packet.Encode()
+		return
+
+	})
+
+	s.SetHandle(ptCrashAck, func(ctx context.Context, req p2p.Data) (res p2p.Data, err error) {
+
+		server.crash.acknowledge()
+
+		res = newCrashAckPacket().Encode()
+		return
+
+	})
+
+	s.SetHandle(ptCameraState, func(ctx context.Context, req p2p.Data) (res p2p.Data, err error) {
+
+		packet := &cameraStatePacket{}
+		err = packet.Decode(req)
+		if err != nil {
+			return
+		}
+
+		// The game thread fills out copies of the results, which are only copied to the packet if the task ran,
+		// so the packet's never written to by both goroutines.
+		hasCamera := false
+		transform := packet.Transform
+
+		if waitErr := server.runOnGameThreadAndWait(func() error {
+
+			if server.t3dCamera == nil {
+				return nil
+			}
+
+			hasCamera = true
+
+			if packet.Set {
+				server.t3dCamera.SetWorldTransform(transform)
+			} else {
+				transform = server.t3dCamera.Transform()
+			}
+
+			return nil
+
+		}); waitErr == nil {
+			packet.HasCamera = hasCamera
+			packet.Transform = transform
+		}
+
+		res = packet.Encode()
+		return
+
+	})
+
+	s.SetHandle(ptTimeControl, func(ctx context.Context, req p2p.Data) (res p2p.Data, err error) {
+
+		packet := &timeControlPacket{}
+		err = packet.Decode(req)
+		if err != nil {
+			return
+		}
+
+		server.handleTimeControl(packet)
+
+		res = packet.Encode()
+		return
+
+	})
+
+	s.SetHandle(ptBreakpoints, func(ctx context.Context, req p2p.Data) (res p2p.Data, err error) {
+
+		packet := &breakpointsPacket{}
+		err = packet.Decode(req)
+		if err != nil {
+			return
+		}
+
+		packet.Breakpoints, packet.Hits = server.breakpoints.info()
+
+		res = packet.Encode()
+		return
+
+	})
+
+	s.SetHandle(ptBreakpointEdit, func(ctx context.Context, req p2p.Data) (res p2p.Data, err error) {
+
+		packet := &breakpointEditPacket{}
+		err = packet.Decode(req)
+		if err != nil {
+			return
+		}
+
+		if editErr := server.editBreakpoint(packet); editErr != nil {
+			packet.Error = editErr.Error()
+		}
+
+		res = packet.Encode()
+		return
+
+	})
+
+	s.SetHandle(ptInput, func(ctx context.Context, req p2p.Data) (res p2p.Data, err error) {
+
+		packet := &inputPacket{}
+		err = packet.Decode(req)
+		if err != nil {
+			return
+		}
+
+		switch packet.Action {
+
+		case inputQuery:
+
+		case inputRecordFetch, inputReplayLoad:
+			// Fetching and loading recordings don't change the game's input, so these can be applied right away.
+			server.input.apply(packet)
+
+		default:
+			// Input (including starting and stopping recordings and replays) is applied on the game thread, so it
+			// changes between ticks rather than partway through one.
+			err = server.runOnGameThreadAndWait(func() error {
+				server.input.apply(packet)
+				return nil
+			})
+			if err != nil {
+				return
+			}
+
+		}
+
+		packet.State = server.input.state()
+		server.input.recordingStatus(packet)
+
+		res = packet.Encode()
+		return
+
+	})
+
+	s.SetHandle(ptDebugDraw, func(ctx context.Context, req p2p.Data) (res p2p.Data, err error) {
+
+		packet := &debugDrawPacket{}
+		err = packet.Decode(req)
+		if err != nil {
+			return
+		}
+
+		server.handleDebugDraw(packet)
+
+		res = packet.Encode()
+		return
+
+	})
+
+	s.SetHandle(ptOverlaySettings, func(ctx context.Context, req p2p.Data) (res p2p.Data, err error) {
+
+		packet := &overlaySettingsPacket{}
+		err = packet.Decode(req)
+		if err != nil {
+			return
+		}
+
+		// The settings are used by Server.Draw(), so they're changed on the game thread.
+		err = server.runOnGameThreadAndWait(func() error {
+			if packet.Set {
+				server.DebugOverlay = packet.Settings
+			}
+			packet.Settings = server.DebugOverlay
+			return nil
+		})
+
+		if err != nil {
+			return
+		}
+
+		res = packet.Encode()
+		return
+
+	})
+
+	s.SetHandle(ptSectors, func(ctx context.Context, req p2p.Data) (res p2p.Data, err error) {
+
+		packet := &sectorPacket{}
+		err = packet.Decode(req)
+		if err != nil {
+			return
+		}
+
+		err = server.runOnGameThreadAndWait(func() error {
+			if sectorErr := server.handleSectors(packet); sectorErr != nil {
+				packet.Error = sectorErr.Error()
+			}
+			return nil
+		})
+
+		if err != nil {
+			return
+		}
+
+		res = packet.Encode()
+		return
+
+	})
+
+	s.SetHandle(ptWorld, func(ctx context.Context, req p2p.Data) (res p2p.Data, err error) {
+
+		packet := &worldPacket{}
+		err = packet.Decode(req)
+		if err != nil {
+			return
+		}
+
+		err = server.runOnGameThreadAndWait(func() error {
+			if worldErr := server.handleWorld(packet); worldErr != nil {
+				packet.Error = worldErr.Error()
+			}
+			return nil
+		})
+
+		if err != nil {
+			return
+		}
+
+		res = packet.Encode()
+		return
+
+	})
+
+	s.SetHandle(ptLights, func(ctx context.Context, req p2p.Data) (res p2p.Data, err error) {
+
+		packet := &lightsPacket{}
+		err = packet.Decode(req)
+		if err != nil {
+			return
+		}
+
+		err = server.runOnGameThreadAndWait(func() error {
+			if lightErr := server.handleLights(packet); lightErr != nil {
+				packet.Error = lightErr.Error()
+			}
+			return nil
+		})
+
+		if err != nil {
+			return
+		}
+
+		res = packet.Encode()
+		return
+
+	})
+
 	s.SetHandle(ptSceneRefresh, func(ctx context.Context, req p2p.Data) (res p2p.Data, err error) {
 
 		packet := &sceneRefreshPacket{}
@@ -545,13 +1138,28 @@ func (server *Server) Update(scene *tetra3d.Scene) {
 	server.activeScene = scene
 	server.activeLibrary = scene.Library()
 
+	tickRan := server.time.tickRan()
+
+	server.input.endTick(tickRan)
+	server.debugShapes.update(tickRan)
+
+	server.tasksMutex.Lock()
+	tasks := server.tasks
+	server.tasks = nil
+	server.tasksMutex.Unlock()
+
+	for _, task := range tasks {
+		task()
+	}
+
 	if server.selectedNode == nil {
 		server.selectedNode = server.activeScene.Root
 	}
 
-	// Scene changed, so we can empty the og transforms list.
+	// Scene changed, so we can empty the og transforms list, as well as the light states saved from the Lights pane.
 	if server.activeScene != server.prevScene {
 		server.ogTransforms = map[tetra3d.INode]ogLocalTransform{}
+		server.lightStates.saved = nil
 	}
 
 	for _, n := range scene.Root.SearchTree().INodes() {
@@ -560,6 +1168,14 @@ func (server *Server) Update(scene *tetra3d.Scene) {
 
 	server.prevScene = server.activeScene
 
+	server.timings.endFrame()
+	server.metrics.sample()
+	server.watches.update()
+	server.tweaks.update()
+	server.updateGizmoPicking()
+	server.nodeData.update(server.selectedNode)
+	server.breakpoints.update(server)
+
 }
 
 // Draw handles any additional drawing from the terminal, drawing to the screen using the Tetra3D
@@ -568,45 +1184,127 @@ func (server *Server) Draw(screen *ebiten.Image, camera *tetra3d.Camera) {
 
 	server.t3dCamera = camera
 
+	// Server.Update() hasn't been called yet.
+	if server.activeScene == nil {
+		return
+	}
+
+	overlay := server.DebugOverlay
+
+	root := server.overlayRoot()
+
 	if server.DebugDrawHierarchy {
 
-		camera.DrawDebugCenters(screen, server.selectedNode, colors.White())
+		camera.DrawDebugCenters(screen, server.selectedNode, overlay.HierarchyColor)
 
 		draw := func(node tetra3d.INode) {
 			if node != camera {
 				nodePos := node.WorldPosition()
-				pos := camera.WorldToScreen(nodePos)
-				if (!node.IsVisible() || camera.WorldPosition().DistanceTo(nodePos) > camera.Far()) && node != server.selectedNode {
+				if debugDepth(camera, nodePos) < camera.Near() {
+					return
+				}
+				if node != server.selectedNode && !overlay.showsName(node, camera) {
 					return
 				}
-				color := colors.Gray()
+				pos := camera.WorldToScreenPixels(nodePos)
+				color := overlay.NameColor
 				if node == server.selectedNode {
-					color = colors.White()
+					color = overlay.SelectedColor
 				}
 				camera.DrawDebugText(screen, node.Name(), pos.X, pos.Y, 1, color)
 			}
 		}
 
-		// for _, n := range server.activeScene.Root.SearchTree().INodes() {
-		// 	if n == server.selectedNode {
-		// 		continue
-		// 	}
-		// 	draw(n)
-		// }
+		if overlay.DrawAllNames {
+			for _, n := range append([]tetra3d.INode{root}, root.SearchTree().INodes()...) {
+				if n == server.selectedNode {
+					continue
+				}
+				draw(n)
+			}
+		}
 
 		draw(server.selectedNode) // Draw the node last so its name is visible
 
 	}
 
 	if server.DebugDrawBounds {
-		drawSettings := tetra3d.DefaultDrawDebugBoundsSettings()
-		drawSettings.RenderBroadphases = false
-		drawSettings.RenderTrianglesAABB = false
-		camera.DrawDebugBoundsColored(screen, server.activeScene.Root, drawSettings)
+		camera.DrawDebugBoundsColored(screen, root, overlay.Bounds)
 	}
 
 	if server.DebugDrawWireframe {
-		camera.DrawDebugWireframe(screen, server.activeScene.Root, colors.LightGray())
+		camera.DrawDebugWireframe(screen, root, overlay.WireframeColor)
+	}
+
+	if server.DebugDrawSectors {
+		server.drawSectors(screen, camera)
+	}
+
+	if server.DebugDrawGizmos {
+		server.drawGizmos(screen, camera, root)
+	}
+
+	if overlay.DrawAllAxes {
+		for _, n := range append([]tetra3d.INode{root}, root.SearchTree().INodes()...) {
+			if n != camera {
+				drawDebugAxes(screen, camera, n, overlay.AxesLength)
+			}
+		}
+	} else if overlay.DrawAxes && server.selectedNode != nil && server.selectedNode != camera {
+		drawDebugAxes(screen, camera, server.selectedNode, overlay.AxesLength)
+	}
+
+	if server.DebugDrawShapes {
+		server.debugShapes.draw(screen, camera)
+	}
+
+	server.highlight.draw(server, screen, camera)
+
+}
+
+// overlayRoot returns the root of the nodes the debug overlays are drawn for; this is the selected node if
+// OverlaySettings.SelectedSubtreeOnly is set, or the active scene's root otherwise.
+func (server *Server) overlayRoot() tetra3d.INode {
+	if server.DebugOverlay.SelectedSubtreeOnly && server.selectedNode != nil {
+		return server.selectedNode
+	}
+	return server.activeScene.Root
+}
+
+// runOnGameThread queues the task given to run during the next call to Server.Update(). This is used to
+// apply changes requested by the terminal safely, as the terminal's requests are handled on other goroutines.
+func (server *Server) runOnGameThread(task func()) {
+	server.tasksMutex.Lock()
+	server.tasks = append(server.tasks, task)
+	server.tasksMutex.Unlock()
+}
+
+// runOnGameThreadAndWait queues the task given to run during the next call to Server.Update(), and waits for
+// it to finish, returning its error. If the game doesn't call Server.Update() soon enough (i.e. if it's paused
+// in a debugger or stuck), the task is cancelled and an error is returned instead, so a change the terminal
+// reports as failed is never applied later.
+func (server *Server) runOnGameThreadAndWait(task func() error) error {
+
+	result := make(chan error, 1)
+
+	// Whichever of the task and the timeout claims this first wins; a task that loses is skipped.
+	claimed := atomic.Bool{}
+
+	server.runOnGameThread(func() {
+		if claimed.CompareAndSwap(false, true) {
+			result <- task()
+		}
+	})
+
+	select {
+	case err := <-result:
+		return err
+	case <-time.After(gameThreadTimeout):
+		if claimed.CompareAndSwap(false, true) {
+			return errors.New("timed out waiting for the game to call Server.Update(); the change wasn't applied")
+		}
+		// The task started running just as the wait timed out, so its result is still reported.
+		return <-result
 	}
 
 }
@@ -656,7 +1354,22 @@ type Display struct {
 	TreeViewScroll *Scrollbar
 
 	NodePropertyArea *tview.TextArea
+	nodeDataView     *valueTreeView
+	consoleOutput    *tview.TextView
+	consoleLines     chan string
+	execDepth        atomic.Int32 // How many scripts are being run through the console's exec command
+	nodeProperties   *tview.Flex
+	selectedNodeID   atomic.Uint32
 	GamePropertyArea *tview.TextArea
+	// GameStatusArea displays the game's time control state and custom metrics, underneath the Game Properties.
+	GameStatusArea *tview.TextView
+
+	// ToolPanes holds the optional tool panes (timings, watches, etc), shown underneath the Game Properties
+	// area when toggled on using their function keys.
+	ToolPanes      *tview.Pages
+	rightSide      *tview.Flex
+	toolPaneKeys   map[tcell.Key]string
+	activeToolPane atomic.Value
 
 	SearchBar                           *tview.InputField
 	SearchBarCloneMode                  bool
@@ -669,6 +1382,27 @@ type Display struct {
 	SelectNextNode      bool
 	SelectNextNodeIndex uint32
 
+	// ProfileDirectory is the directory that profiles captured from the game are saved to; an empty string
+	// means the current working directory.
+	ProfileDirectory string
+	capturingProfile atomic.Bool
+
+	process             gameProcess
+	processOutput       *tview.TextView
+	processStatus       *tview.TextView
+	followProcessOutput bool
+
+	// CrashDirectory is the directory that crash reports received from the game are saved to; an empty string
+	// means the current working directory.
+	CrashDirectory string
+
+	// RecordingDirectory is the directory that input recordings captured from the game are saved to; an empty
+	// string means the current working directory.
+	RecordingDirectory string
+
+	overlayOptionsForm   *tview.Form
+	overlayOptionsStatus *tview.TextView
+
 	SceneNodesToTreeNodes map[uint32]*tview.TreeNode
 	// DebugDraw    bool
 
@@ -699,6 +1433,7 @@ func NewDisplay(settings *ConnectionSettings) *Display {
 		running: atomic.Bool{},
 
 		SceneNodesToTreeNodes: map[uint32]*tview.TreeNode{},
+		toolPaneKeys:          map[tcell.Key]string{},
 
 		// Flexbox: tview.NewFlex(),
 
@@ -715,16 +1450,76 @@ func NewDisplay(settings *ConnectionSettings) *Display {
 	app.Root = tview.NewPages()
 	app.Root.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
 
-		if event.Rune() == '1' {
-			app.sendRequest(newToggleDebugDrawHierarchy())
+		// Hotkeys shouldn't trigger while typing into a field, or while a tool pane (like the Input pane, which
+		// forwards keys to the game) is focused.
+		if !app.typing() && !app.ToolPanes.HasFocus() {
+
+			if event.Rune() == '1' {
+				app.sendRequest(newToggleDebugDrawHierarchy())
+			}
+
+			if event.Rune() == '2' {
+				app.sendRequest(newToggleDebugDrawWireframe())
+			}
+
+			if event.Rune() == '3' {
+				app.sendRequest(newToggleDebugDrawBounds())
+			}
+
+			if event.Rune() == '4' {
+				app.sendRequest(newToggleDebugDrawShapes())
+			}
+
+			if event.Rune() == '5' {
+				app.sendRequest(newToggleDebugDrawGizmos())
+			}
+
 		}
 
-		if event.Rune() == '2' {
-			app.sendRequest(newToggleDebugDrawWireframe())
+		// Time controls, which are left to tool panes when they're focused, as they have their own keys.
+		if !app.typing() && !app.ToolPanes.HasFocus() {
+
+			switch event.Rune() {
+			case 'p':
+				app.sendTimeControl(timeControlTogglePause, 0, 0)
+				return nil
+			case '.':
+				app.sendTimeControl(timeControlStep, 1, 0)
+				return nil
+			case '>':
+				app.promptStep()
+				return nil
+			case '[':
+				app.changeTimeScale(0.5)
+				return nil
+			case ']':
+				app.changeTimeScale(2)
+				return nil
+			case '=':
+				app.sendTimeControl(timeControlSetScale, 0, 1)
+				return nil
+			}
+
 		}
 
-		if event.Rune() == '3' {
-			app.sendRequest(newToggleDebugDrawBounds())
+		if paneName, exists := app.toolPaneKeys[event.Key()]; exists {
+			app.toggleToolPane(paneName)
+			return nil
+		}
+
+		if event.Key() == tcell.KeyEscape && app.ToolPanes.HasFocus() {
+			app.App.SetFocus(app.TreeView)
+			return nil
+		}
+
+		if event.Key() == tcell.KeyCtrlP {
+			app.Root.ShowPage("profile")
+			return nil
+		}
+
+		if event.Key() == tcell.KeyCtrlD && !app.typing() {
+			app.showOverlayOptions()
+			return nil
 		}
 
 		if event.Key() == tcell.KeyCtrlQ {
@@ -827,7 +1622,10 @@ func NewDisplay(settings *ConnectionSettings) *Display {
 				for _, node := range app.currentSceneTree.ChildrenRecursive() {
 
 					if strings.Contains(strings.ToLower(node.Name), strings.ToLower(text)) {
-						treeNode := app.SceneNodesToTreeNodes[node.NodeID]
+						treeNode, exists := app.SceneNodesToTreeNodes[node.NodeID]
+						if !exists {
+							continue
+						}
 						// treeNode.ExpandAll() // Not necessary if we expand the root above
 						app.TreeView.SetCurrentNode(treeNode)
 						app.sendRequest(newNodeSelectPacket(node.NodeID))
@@ -894,6 +1692,7 @@ Shift+D: Duplicate Node
 Shift+X: Delete Node
 
 F: Follow Node with Camera
+I: Inspect / Edit Node Data & Inspectors
 Shift+F: Search Nodes
 Shift+C: Clone Nodes
 `
@@ -903,6 +1702,27 @@ Shift+C: Clone Nodes
 1: Toggle Debug Hierarchy Drawing
 2: Toggle Debug Wireframe Drawing
 3: Toggle Debug Bounds Drawing
+4: Toggle Debug Shapes Drawing (Server.DebugLine(), etc.)
+5: Toggle Light, Camera, and Path Gizmos (Ctrl+Click a gizmo in the game to select it)
+Ctrl+P: Capture CPU / Heap Profile or Trace
+Ctrl+D: Debug Overlay Options (colors, names, axes, etc.)
+P: Pause / Resume Game (see Server.Paused())
+., >: Advance Paused Game 1 / N Ticks
+[, ], =: Slow Down, Speed Up, Reset Time Scale
+F2: Toggle Timing Scopes Pane
+F3: Toggle Watches Pane
+F4: Toggle Tweaks Pane
+F5: Toggle Actions Pane
+F6: Toggle Console Pane
+F7: Toggle Log Pane
+F8: Toggle Crash Report Pane
+F9: Toggle Game Process Pane (when started with tetraterm run)
+F10: Toggle Breakpoints Pane
+F11: Toggle Input Pane (type into it to press keys in the game)
+F12: Toggle Debug Overlay Pane
+Shift+F1: Toggle Sectors Pane
+Shift+F2: Toggle World Pane (fog, ambient light, clear color)
+Shift+F3: Toggle Lights Pane
 Ctrl+R: Force Terminal Refresh (if it gets corrupted)
 Ctrl+Q : Quit (Ctrl+C also works)
 `
@@ -946,17 +1766,59 @@ Ctrl+Q : Quit (Ctrl+C also works)
 	app.NodePropertyArea.SetTextStyle(style)
 	app.NodePropertyArea.SetBorder(true)
 	app.NodePropertyArea.SetTitle("[ Node Properties ]")
-	rightSide.AddItem(app.NodePropertyArea, 0, 1, false)
+
+	app.initNodeDataView()
+
+	app.nodeProperties = tview.NewFlex()
+	app.nodeProperties.SetDirection(tview.FlexRow)
+	app.nodeProperties.AddItem(app.NodePropertyArea, 0, 1, false)
+	app.nodeProperties.AddItem(app.nodeDataView, 0, 0, false)
+	rightSide.AddItem(app.nodeProperties, 0, 1, false)
 
 	app.GamePropertyArea = tview.NewTextArea()
 	app.GamePropertyArea.SetBackgroundColor(tcell.ColorDefault)
 	app.GamePropertyArea.SetTextStyle(style)
 	app.GamePropertyArea.SetBorder(true)
 	app.GamePropertyArea.SetTitle("[ Game Properties ]")
-	rightSide.AddItem(app.GamePropertyArea, 0, 1, false)
+
+	// The status is a TextView, rather than a TextArea, as it uses color tags to highlight metrics and the time
+	// control state.
+	app.GameStatusArea = tview.NewTextView()
+	app.GameStatusArea.SetBackgroundColor(tcell.ColorDefault)
+	app.GameStatusArea.SetTextStyle(style)
+	app.GameStatusArea.SetDynamicColors(true)
+	app.GameStatusArea.SetBorder(true)
+	app.GameStatusArea.SetTitle("[ Game Status ]")
+
+	gameArea := tview.NewFlex()
+	gameArea.SetDirection(tview.FlexRow)
+	gameArea.AddItem(app.GamePropertyArea, 0, 3, false)
+	gameArea.AddItem(app.GameStatusArea, 0, 2, false)
+	rightSide.AddItem(gameArea, 0, 1, false)
+
+	app.ToolPanes = tview.NewPages()
+	rightSide.AddItem(app.ToolPanes, 0, 0, false)
+	app.rightSide = rightSide
+
+	app.initTimingPane()
+	app.initWatchPane()
+	app.initTweakPane()
+	app.initActionPane()
+	app.initConsolePane()
+	app.initLogPane()
+	app.initCrashPane()
+	app.initProcessPane()
+	app.initBreakpointPane()
+	app.initInputPane()
+	app.initDebugOverlayPane()
+	app.initSectorPane()
+	app.initWorldPane()
+	app.initLightPane()
 
 	app.Root.AddAndSwitchToPage("Tree View", overallFlex, true)
 	app.Root.AddPage(keysExplanation.Name, keysExplanation, true, false)
+	app.initProfilePage()
+	app.initOverlayOptionsPage()
 
 	go func() {
 
@@ -1062,8 +1924,10 @@ Ctrl+Q : Quit (Ctrl+C also works)
 			if err == nil {
 				info := resp.(*nodeInfoPacket)
 				text := fmt.Sprintf("ID:%d\nVisible:%t\nType:%s\n\nPos:%v\nSca:%v\nRot:\n%v", info.ID, info.Visible, info.Type, info.Position, info.Scale, info.Rotation)
+				app.selectedNodeID.Store(info.ID)
 				app.App.QueueUpdate(func() {
 					app.NodePropertyArea.SetText(text, false)
+					app.setNodeData(info.Data, info.HasData, info.Inspectors)
 				})
 			}
 
@@ -1086,6 +1950,12 @@ Ctrl+Q : Quit (Ctrl+C also works)
 			if err == nil {
 				info := resp.(*gameInfoPacket)
 
+				if info.HasPickedNode {
+					app.App.QueueUpdate(func() {
+						app.showTreeNode(info.PickedNode, sceneTreeAncestors(app.currentSceneTree, info.PickedNode))
+					})
+				}
+
 				m := info.DebugInfo.FrameTime.Round(time.Microsecond).Microseconds()
 				l := info.DebugInfo.LightTime.Round(time.Microsecond).Microseconds()
 				a := info.DebugInfo.AnimationTime.Round(time.Microsecond).Microseconds()
@@ -1130,8 +2000,15 @@ Ctrl+Q : Quit (Ctrl+C also works)
 					text += fmt.Sprintf("\n---------\nCurrent Sector: %s\n%d Neighboring Visible Sectors:%s", sectorName, len(info.SectorNeighbors), neighboringSectors)
 				}
 
+				status := formatTimeState(info.Paused, info.Steps, info.TimeScale)
+
+				if len(info.Metrics) > 0 {
+					status += "\n---------\nMetrics:" + formatMetrics(info.Metrics)
+				}
+
 				app.App.QueueUpdate(func() {
 					app.GamePropertyArea.SetText(text, false)
+					app.GameStatusArea.SetText(status)
 				})
 				// fmt.Println(info)
 			}
@@ -1167,6 +2044,11 @@ Ctrl+Q : Quit (Ctrl+C also works)
 			return nil
 		}
 
+		if event.Rune() == 'i' {
+			app.App.SetFocus(app.nodeDataView)
+			return nil
+		}
+
 		if event.Rune() == 'F' {
 			app.SearchBar.SetText("")
 			app.SearchBar.SetLabel("Search Node: ")
@@ -1341,6 +2223,7 @@ func (td *Display) initClient() {
 	// If retry is high, then the terminal spams the server with requests
 	// If it's 0, then we never attempt a reconnection if the server ends unexpectedly
 	settings.SetRetry(1, time.Millisecond*500)
+	settings.SetConnTimeout(connectionTimeout)
 	client.SetSettings(settings)
 
 	if td.ClientSettings.SilentLogging {
@@ -1378,6 +2261,7 @@ func (td *Display) Start() error {
 func (td *Display) Stop() {
 	td.App.Stop()
 	td.running.Store(false)
+	td.killGameProcess()
 }
 
 func (td *Display) sendRequest(packet iPacket) (iPacket, error) {
@@ -1392,11 +2276,56 @@ func (td *Display) sendRequest(packet iPacket) (iPacket, error) {
 
 }
 
+// typing returns if the focused element is a field that can be typed into.
+func (display *Display) typing() bool {
+	switch display.App.GetFocus().(type) {
+	case *tview.InputField, *tview.TextArea, *tview.DropDown:
+		return true
+	}
+	return false
+}
+
+// addToolPane adds a tool pane to the Display, toggled by pressing the given key.
+func (display *Display) addToolPane(name string, key tcell.Key, pane tview.Primitive) {
+	display.ToolPanes.AddPage(name, pane, true, false)
+	display.toolPaneKeys[key] = name
+}
+
+// toggleToolPane shows the named tool pane underneath the Game Properties area, or hides it if it's already shown.
+func (display *Display) toggleToolPane(name string) {
+
+	if display.toolPaneVisible(name) {
+		display.ToolPanes.HidePage(name)
+		display.rightSide.ResizeItem(display.ToolPanes, 0, 0)
+		display.activeToolPane.Store("")
+		display.App.SetFocus(display.TreeView)
+		return
+	}
+
+	display.ToolPanes.SwitchToPage(name)
+	display.activeToolPane.Store(name)
+	display.rightSide.ResizeItem(display.ToolPanes, 0, 2)
+	_, pane := display.ToolPanes.GetFrontPage()
+	display.App.SetFocus(pane)
+
+}
+
+// toolPaneVisible returns if the named tool pane is currently being shown. This is safe to call
+// from outside of the UI goroutine, so tool panes can skip polling the server while they're hidden.
+func (display *Display) toolPaneVisible(name string) bool {
+	active, _ := display.activeToolPane.Load().(string)
+	return active == name
+}
+
 func (display *Display) updateTreeNodeNames() {
 
 	for _, node := range display.currentSceneTree.ChildrenRecursive() {
 
-		tn := display.SceneNodesToTreeNodes[node.NodeID]
+		// The tree's nodes are forgotten when the game restarts, until the new scene's received.
+		tn, exists := display.SceneNodesToTreeNodes[node.NodeID]
+		if !exists {
+			continue
+		}
 
 		name := node.Name
 
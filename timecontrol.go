@@ -0,0 +1,220 @@
+package tetraterm
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+)
+
+// The limits of the time scale that can be set from the terminal.
+const (
+	minTimeScale = 1.0 / 16
+	maxTimeScale = 16.0
+)
+
+const (
+	timeControlQuery = iota
+	timeControlTogglePause
+	timeControlStep
+	timeControlSetScale
+)
+
+// timeControl holds the pause, step and time scale state set from the terminal for the game to query.
+type timeControl struct {
+	mutex     sync.Mutex
+	paused    bool
+	steps     int
+	timeScale float64
+
+	// stepped is set when a tick is advanced through Server.ShouldStep(), until the next call to Server.Update().
+	stepped bool
+}
+
+// Paused returns if the game has been paused from the terminal. While paused, the game should skip updating
+// its simulation, except for ticks where Server.ShouldStep() returns true. Note that Server.Update() should
+// still be called while the game is paused, so the terminal can continue to inspect and alter the game.
+//
+// Example usage:
+//
+//	func (g *Game) Update() error {
+//		if !g.DebugServer.Paused() || g.DebugServer.ShouldStep() {
+//			g.UpdateSimulation(g.DebugServer.TimeScale())
+//		}
+//		g.DebugServer.Update(g.Scene)
+//		return nil
+//	}
+func (server *Server) Paused() bool {
+	server.time.mutex.Lock()
+	defer server.time.mutex.Unlock()
+	return server.time.paused
+}
+
+// SetPaused pauses or unpauses the game, as though it were done from the terminal.
+func (server *Server) SetPaused(paused bool) {
+	server.time.mutex.Lock()
+	defer server.time.mutex.Unlock()
+	server.time.paused = paused
+	server.time.steps = 0
+}
+
+// ShouldStep returns true if the game is paused and a single tick should be advanced, as requested from the
+// terminal. Each call consumes one of the requested ticks, so ShouldStep should be called once per tick.
+func (server *Server) ShouldStep() bool {
+
+	server.time.mutex.Lock()
+	defer server.time.mutex.Unlock()
+
+	if !server.time.paused || server.time.steps <= 0 {
+		return false
+	}
+
+	server.time.steps--
+	server.time.stepped = true
+
+	return true
+
+}
+
+// TimeScale returns the speed the game should run at, as set from the terminal; 1 is normal speed, 0.5 is half
+// speed, and so on. The game should scale the time that passes each tick by this value.
+func (server *Server) TimeScale() float64 {
+
+	server.time.mutex.Lock()
+	defer server.time.mutex.Unlock()
+
+	if server.time.timeScale == 0 {
+		return 1
+	}
+
+	return server.time.timeScale
+
+}
+
+// SetTimeScale sets the speed the game should run at, as though it were done from the terminal.
+func (server *Server) SetTimeScale(scale float64) {
+	server.time.mutex.Lock()
+	defer server.time.mutex.Unlock()
+	server.time.timeScale = scale
+}
+
+// tickRan returns if the game updated its simulation since the last call to tickRan(), which is the case if it
+// isn't paused or was stepped. This is called on the game thread from Server.Update().
+func (time *timeControl) tickRan() bool {
+	time.mutex.Lock()
+	defer time.mutex.Unlock()
+	ran := !time.paused || time.stepped
+	time.stepped = false
+	return ran
+}
+
+// handleTimeControl applies the time control action given in the packet, filling it out with the resulting state.
+func (server *Server) handleTimeControl(packet *timeControlPacket) {
+
+	switch packet.Action {
+
+	case timeControlTogglePause:
+		server.SetPaused(!server.Paused())
+
+	case timeControlStep:
+		server.time.mutex.Lock()
+		// Stepping pauses the game if it isn't already paused.
+		server.time.paused = true
+		server.time.steps += packet.Steps
+		server.time.mutex.Unlock()
+
+	case timeControlSetScale:
+		scale := packet.Scale
+		if scale < minTimeScale {
+			scale = minTimeScale
+		}
+		if scale > maxTimeScale {
+			scale = maxTimeScale
+		}
+		server.SetTimeScale(scale)
+
+	}
+
+	server.time.mutex.Lock()
+	packet.Paused = server.time.paused
+	packet.Steps = server.time.steps
+	server.time.mutex.Unlock()
+
+	packet.Scale = server.TimeScale()
+
+}
+
+// formatTimeState returns the pause and time scale state for display in the Game Status area.
+func formatTimeState(paused bool, steps int, scale float64) string {
+
+	text := "Time: "
+
+	if paused {
+		text += "[yellow]Paused[-]"
+		if steps > 0 {
+			text += fmt.Sprintf(" (%d ticks queued)", steps)
+		}
+	} else {
+		text += "[green]Running[-]"
+	}
+
+	if scale != 1 {
+		text += fmt.Sprintf("\nTime Scale: [yellow]%gx[-]", scale)
+	} else {
+		text += "\nTime Scale: 1x"
+	}
+
+	return text
+
+}
+
+// sendTimeControl sends a time control action to the game.
+func (display *Display) sendTimeControl(action, steps int, scale float64) {
+
+	packet := newTimeControlPacket(action)
+	packet.Steps = steps
+	packet.Scale = scale
+
+	go display.sendRequest(packet)
+
+}
+
+// changeTimeScale multiplies the game's time scale by the factor given.
+func (display *Display) changeTimeScale(factor float64) {
+
+	go func() {
+
+		res, err := display.sendRequest(newTimeControlPacket(timeControlQuery))
+		if err != nil {
+			return
+		}
+
+		packet := newTimeControlPacket(timeControlSetScale)
+		packet.Scale = res.(*timeControlPacket).Scale * factor
+		display.sendRequest(packet)
+
+	}()
+
+}
+
+// promptStep prompts for a number of ticks to advance the game by.
+func (display *Display) promptStep() {
+
+	display.promptValue("Advance Ticks", "10", func(text string) error {
+
+		steps, err := strconv.Atoi(text)
+		if err != nil {
+			return err
+		}
+
+		if steps <= 0 {
+			return errors.New("the number of ticks must be positive")
+		}
+
+		display.sendTimeControl(timeControlStep, steps, 0)
+
+		return nil
+
+	})
+
+}
@@ -0,0 +1,392 @@
+package tetraterm
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+// How many frames of history are kept for each timing scope.
+const timingHistoryLength = 60
+
+// How many frames a timing scope can be left running for before it's assumed it'll never be ended, and scopes
+// begun afterwards are no longer nested under it.
+const timingScopeOpenLimit = timingHistoryLength
+
+var sparklineRunes = []rune("▁▂▃▄▅▆▇█")
+
+// TimingScope represents a named section of your game's code that is being timed. Create one using
+// Server.BeginScope(), and call End() on it when the section of code is done.
+type TimingScope struct {
+	server *Server
+	path   string
+	start  time.Time
+	ended  bool
+}
+
+// End ends the timing scope, adding the time elapsed since it began to its total for the current frame.
+// Calling End() more than once has no further effect.
+func (scope *TimingScope) End() {
+
+	elapsed := time.Since(scope.start)
+
+	timings := &scope.server.timings
+
+	timings.mutex.Lock()
+	defer timings.mutex.Unlock()
+
+	if scope.ended {
+		return
+	}
+
+	scope.ended = true
+
+	// Scopes should be ended in the reverse order of how they were begun, but if they aren't, we
+	// just remove the scope from wherever it is in the stack.
+	for i := len(timings.stack) - 1; i >= 0; i-- {
+		if timings.stack[i].path == scope.path {
+			timings.stack = append(timings.stack[:i], timings.stack[i+1:]...)
+			break
+		}
+	}
+
+	if timings.frame == nil {
+		timings.frame = map[string]time.Duration{}
+	}
+
+	timings.frame[scope.path] += elapsed
+
+}
+
+type timingHistory struct {
+	samples []time.Duration
+	index   int
+	filled  int // How many of the samples have been recorded, up to timingHistoryLength
+	idle    int
+}
+
+// openTimingScope is a timing scope that's been begun, but not yet ended.
+type openTimingScope struct {
+	path   string
+	frames int // How many frames the scope's been running across
+}
+
+// timingScopes aggregates the durations of all timing scopes over the past several frames.
+type timingScopes struct {
+	mutex   sync.Mutex
+	stack   []openTimingScope
+	frame   map[string]time.Duration
+	history map[string]*timingHistory
+}
+
+// endFrame moves the durations recorded over the current frame into each scope's history.
+func (timings *timingScopes) endFrame() {
+
+	timings.mutex.Lock()
+	defer timings.mutex.Unlock()
+
+	if timings.history == nil {
+		timings.history = map[string]*timingHistory{}
+	}
+
+	for path := range timings.frame {
+		if _, exists := timings.history[path]; !exists {
+			timings.history[path] = &timingHistory{samples: make([]time.Duration, timingHistoryLength)}
+		}
+	}
+
+	for path, history := range timings.history {
+
+		duration, ran := timings.frame[path]
+
+		history.samples[history.index] = duration
+		history.index = (history.index + 1) % timingHistoryLength
+		if history.filled < timingHistoryLength {
+			history.filled++
+		}
+
+		if ran {
+			history.idle = 0
+		} else {
+			history.idle++
+			// Scopes that haven't run over the entire history are dropped.
+			if history.idle >= timingHistoryLength {
+				delete(timings.history, path)
+			}
+		}
+
+	}
+
+	timings.frame = map[string]time.Duration{}
+
+	// Scopes can run across frames, but one that's been left running for too long (i.e. by an early return or a
+	// panic) would otherwise have every scope begun afterwards nested under it, so it's dropped from the stack.
+	// Ending it afterwards still records its duration.
+	stack := timings.stack[:0]
+
+	for _, open := range timings.stack {
+		open.frames++
+		if open.frames > timingScopeOpenLimit {
+			log.Printf("warning: timing scope %q has been running for over %d frames without being ended; scopes begun afterwards won't be nested under it", open.path, timingScopeOpenLimit)
+			continue
+		}
+		stack = append(stack, open)
+	}
+
+	timings.stack = stack
+
+}
+
+// info returns the aggregated information for each timing scope.
+func (timings *timingScopes) info() []timingScopeInfo {
+
+	timings.mutex.Lock()
+	defer timings.mutex.Unlock()
+
+	out := make([]timingScopeInfo, 0, len(timings.history))
+
+	for path, history := range timings.history {
+
+		info := timingScopeInfo{
+			Path:    path,
+			History: make([]time.Duration, 0, timingHistoryLength),
+		}
+
+		total := time.Duration(0)
+
+		// Only the samples that have been recorded are included, so new scopes' averages aren't skewed by empty ones.
+		for i := timingHistoryLength - history.filled; i < timingHistoryLength; i++ {
+			sample := history.samples[(history.index+i)%timingHistoryLength]
+			info.History = append(info.History, sample)
+			total += sample
+			if sample > info.Max {
+				info.Max = sample
+			}
+		}
+
+		if len(info.History) > 0 {
+			info.Last = info.History[len(info.History)-1]
+			info.Average = total / time.Duration(len(info.History))
+		}
+
+		out = append(out, info)
+
+	}
+
+	return out
+
+}
+
+// BeginScope begins timing a named scope of your game's code, returning the TimingScope; call End() on it when
+// the section of code you wish to time is finished. Scopes begun while another scope is still running are nested
+// under that scope in the terminal's Timing Scopes pane. The durations of scopes are summed up over each frame
+// (from one call to Server.Update() to the next), so a scope may be begun and ended multiple times per frame.
+// Scope names shouldn't contain forward slashes, as these are used to separate nested scopes.
+//
+// Example usage:
+//
+//	scope := server.BeginScope("physics")
+//	updatePhysics()
+//	scope.End()
+func (server *Server) BeginScope(name string) *TimingScope {
+
+	server.timings.mutex.Lock()
+
+	path := name
+	if len(server.timings.stack) > 0 {
+		path = server.timings.stack[len(server.timings.stack)-1].path + "/" + name
+	}
+	server.timings.stack = append(server.timings.stack, openTimingScope{path: path})
+
+	server.timings.mutex.Unlock()
+
+	return &TimingScope{
+		server: server,
+		path:   path,
+		start:  time.Now(),
+	}
+
+}
+
+// Time times the function given as a named scope; see Server.BeginScope().
+func (server *Server) Time(name string, fn func()) {
+	scope := server.BeginScope(name)
+	defer scope.End()
+	fn()
+}
+
+// initTimingPane creates the Timing Scopes tool pane, which displays a breakdown of the timing scopes created
+// through Server.BeginScope() and Server.Time().
+func (display *Display) initTimingPane() {
+
+	pane := tview.NewTextView()
+	pane.SetDynamicColors(true)
+	pane.SetBackgroundColor(tcell.ColorDefault)
+	pane.SetBorder(true)
+	pane.SetTitle("[ Timing Scopes ]")
+	pane.SetText("No timing scopes recorded.")
+
+	display.addToolPane("timings", tcell.KeyF2, pane)
+
+	go func() {
+
+		for {
+
+			time.Sleep(time.Millisecond * 250)
+
+			if !display.running.Load() {
+				return
+			}
+
+			if !display.toolPaneVisible("timings") {
+				continue
+			}
+
+			resp, err := display.sendRequest(newTimingScopesPacket())
+			if err == nil {
+				text := formatTimingScopes(resp.(*timingScopesPacket).Scopes)
+				display.App.QueueUpdate(func() {
+					pane.SetText(text)
+				})
+			}
+
+		}
+
+	}()
+
+}
+
+// formatTimingScopes returns the timing scopes given as a nested breakdown, with each scope's children
+// sorted by their average duration.
+func formatTimingScopes(scopes []timingScopeInfo) string {
+
+	if len(scopes) == 0 {
+		return "No timing scopes recorded."
+	}
+
+	paths := map[string]bool{}
+	for _, scope := range scopes {
+		paths[scope.Path] = true
+	}
+
+	children := map[string][]timingScopeInfo{}
+
+	// Scopes are listed under their closest ancestor that's still recorded, or at the top level if there are none
+	// (i.e. if the parent scope was dropped for not running recently).
+	for _, scope := range scopes {
+		parent := scope.Path
+		for {
+			i := strings.LastIndex(parent, "/")
+			if i < 0 {
+				parent = ""
+				break
+			}
+			parent = parent[:i]
+			if paths[parent] {
+				break
+			}
+		}
+		children[parent] = append(children[parent], scope)
+	}
+
+	text := fmt.Sprintf("[::b]%-28s %9s %9s %9s  History[::-]\n", "Scope", "Last", "Avg.", "Max")
+
+	var loop func(parent string, depth int)
+
+	loop = func(parent string, depth int) {
+
+		siblings := children[parent]
+
+		sort.Slice(siblings, func(i, j int) bool {
+			if siblings[i].Average == siblings[j].Average {
+				return siblings[i].Path < siblings[j].Path
+			}
+			return siblings[i].Average > siblings[j].Average
+		})
+
+		for _, scope := range siblings {
+
+			name := scope.Path
+			if parent != "" {
+				name = strings.TrimPrefix(name, parent+"/")
+			}
+			name = strings.Repeat("  ", depth) + name
+
+			text += fmt.Sprintf("%-28s %9s %9s %9s  [green]%s[white]\n",
+				tview.Escape(name),
+				formatMilliseconds(scope.Last),
+				formatMilliseconds(scope.Average),
+				formatMilliseconds(scope.Max),
+				sparkline(durationsToFloats(scope.History), 30),
+			)
+
+			loop(scope.Path, depth+1)
+
+		}
+
+	}
+
+	loop("", 0)
+
+	return text
+
+}
+
+func formatMilliseconds(duration time.Duration) string {
+	return fmt.Sprintf("%.2fms", float64(duration.Microseconds())/1000)
+}
+
+func durationsToFloats(durations []time.Duration) []float64 {
+	out := make([]float64, 0, len(durations))
+	for _, d := range durations {
+		out = append(out, float64(d))
+	}
+	return out
+}
+
+// sparkline returns a graph of the last count samples given, scaled between the minimum and maximum
+// of those samples (or zero, if all samples are positive).
+func sparkline(samples []float64, count int) string {
+
+	if len(samples) > count {
+		samples = samples[len(samples)-count:]
+	}
+
+	min, max := 0.0, 0.0
+	for _, s := range samples {
+		if math.IsNaN(s) || math.IsInf(s, 0) {
+			continue
+		}
+		if s > max {
+			max = s
+		}
+		if s < min {
+			min = s
+		}
+	}
+
+	line := make([]rune, 0, len(samples))
+
+	for _, s := range samples {
+		index := 0
+		if position := (s - min) / (max - min); max > min && !math.IsNaN(position) && !math.IsInf(position, 0) {
+			index = int(position * float64(len(sparklineRunes)-1))
+		}
+		if index < 0 {
+			index = 0
+		} else if index >= len(sparklineRunes) {
+			index = len(sparklineRunes) - 1
+		}
+		line = append(line, sparklineRunes[index])
+	}
+
+	return string(line)
+
+}
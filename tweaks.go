@@ -0,0 +1,513 @@
+package tetraterm
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+// TweakOptions controls how a value registered through Server.Tweak() can be edited in the terminal.
+type TweakOptions struct {
+	// The range that numeric values are clamped to. If Min and Max are both 0, values aren't clamped.
+	// Ranged values are also displayed with a slider.
+	Min, Max float64
+	// How much numeric values change when stepped using the Up and Down keys in the terminal. If Step is 0,
+	// it defaults to 1 for integers and 0.1 for floats.
+	Step float64
+	// The names of the values of an enum. If Choices is set, the pointer given to Server.Tweak() should either
+	// point to an integer, in which case the value is the index of the chosen choice, or to a string, in which
+	// case the value is the chosen choice itself.
+	Choices []string
+}
+
+type tweak struct {
+	name    string
+	kind    int
+	value   reflect.Value
+	options TweakOptions
+}
+
+func (t *tweak) String() string {
+
+	switch t.kind {
+	case tweakKindFloat:
+		return strconv.FormatFloat(t.value.Float(), 'f', -1, 64)
+	case tweakKindBool:
+		return strconv.FormatBool(t.value.Bool())
+	case tweakKindString:
+		return t.value.String()
+	case tweakKindEnum:
+		if t.value.Kind() == reflect.String {
+			return t.value.String()
+		}
+		if index := int(integerValue(t.value)); index >= 0 && index < len(t.options.Choices) {
+			return t.options.Choices[index]
+		}
+	}
+
+	return strconv.FormatFloat(integerValue(t.value), 'f', -1, 64)
+
+}
+
+// parse parses the text given into a new value for the tweak.
+func (t *tweak) parse(text string) (reflect.Value, error) {
+
+	value := reflect.New(t.value.Type()).Elem()
+
+	clamp := func(v float64) float64 {
+		if t.options.Min != 0 || t.options.Max != 0 {
+			if v < t.options.Min {
+				v = t.options.Min
+			}
+			if v > t.options.Max {
+				v = t.options.Max
+			}
+		}
+		return v
+	}
+
+	switch t.kind {
+
+	case tweakKindFloat:
+		f, err := strconv.ParseFloat(strings.TrimSpace(text), 64)
+		if err != nil {
+			return value, err
+		}
+		value.SetFloat(clamp(f))
+
+	case tweakKindInt:
+		f, err := strconv.ParseFloat(strings.TrimSpace(text), 64)
+		if err != nil {
+			return value, err
+		}
+		if err := setIntegerValue(value, clamp(f)); err != nil {
+			return value, err
+		}
+
+	case tweakKindBool:
+		b, err := strconv.ParseBool(strings.TrimSpace(text))
+		if err != nil {
+			return value, err
+		}
+		value.SetBool(b)
+
+	case tweakKindString:
+		value.SetString(text)
+
+	case tweakKindEnum:
+		index := -1
+		for i, choice := range t.options.Choices {
+			if choice == text {
+				index = i
+				break
+			}
+		}
+		if index < 0 {
+			return value, errors.New(strconv.Quote(text) + " isn't a valid choice")
+		}
+		if value.Kind() == reflect.String {
+			value.SetString(text)
+		} else if err := setIntegerValue(value, float64(index)); err != nil {
+			return value, err
+		}
+
+	}
+
+	return value, nil
+
+}
+
+func integerValue(value reflect.Value) float64 {
+	if value.CanInt() {
+		return float64(value.Int())
+	}
+	return float64(value.Uint())
+}
+
+func setIntegerValue(value reflect.Value, f float64) error {
+
+	if value.CanInt() {
+		if value.OverflowInt(int64(f)) {
+			return errors.New(strconv.FormatFloat(f, 'f', -1, 64) + " is out of range for " + value.Type().String())
+		}
+		value.SetInt(int64(f))
+		return nil
+	}
+
+	if f < 0 || value.OverflowUint(uint64(f)) {
+		return errors.New(strconv.FormatFloat(f, 'f', -1, 64) + " is out of range for " + value.Type().String())
+	}
+	value.SetUint(uint64(f))
+	return nil
+
+}
+
+// tweakList holds the values registered through Server.Tweak().
+type tweakList struct {
+	mutex     sync.Mutex
+	tweaks    []*tweak
+	values    []tweakInfo
+	requested time.Time
+}
+
+// update records the current value of each tweak, but only if the terminal has asked for them recently;
+// this is called on the game thread from Server.Update().
+func (tweaks *tweakList) update() {
+
+	tweaks.mutex.Lock()
+	defer tweaks.mutex.Unlock()
+
+	if time.Since(tweaks.requested) > watchRequestTimeout {
+		return
+	}
+
+	tweaks.values = tweaks.values[:0]
+
+	for _, t := range tweaks.tweaks {
+		tweaks.values = append(tweaks.values, tweakInfo{
+			Name:    t.name,
+			Kind:    t.kind,
+			Value:   t.String(),
+			Options: t.options,
+		})
+	}
+
+}
+
+// latest returns the most recently recorded tweak values, and marks the tweaks as having been requested.
+func (tweaks *tweakList) latest() []tweakInfo {
+
+	tweaks.mutex.Lock()
+	defer tweaks.mutex.Unlock()
+
+	tweaks.requested = time.Now()
+
+	return append([]tweakInfo{}, tweaks.values...)
+
+}
+
+// set parses the text given as a new value for the named tweak, returning a function that applies the
+// new value; this function should be called on the game thread.
+func (tweaks *tweakList) set(name, text string) (func(), error) {
+
+	tweaks.mutex.Lock()
+	defer tweaks.mutex.Unlock()
+
+	for _, t := range tweaks.tweaks {
+
+		if t.name == name {
+
+			value, err := t.parse(text)
+			if err != nil {
+				return nil, err
+			}
+
+			target := t.value
+			return func() { target.Set(value) }, nil
+
+		}
+
+	}
+
+	return nil, errors.New("no tweak named " + strconv.Quote(name))
+
+}
+
+// Tweak registers a pointer to a value that can be edited live from the terminal's Tweaks pane. The pointer
+// can point to a float, integer, bool or string value. Changes made in the terminal are applied on the game
+// thread during the next call to Server.Update(). Options controls the range, step size and choices for the
+// value; passing nil uses the default options. Registering a tweak with the name of an existing tweak
+// replaces it.
+//
+// Example usage:
+//
+//	server.Tweak("gravity", &game.Gravity, &tetraterm.TweakOptions{Min: 0, Max: 50, Step: 0.5})
+func (server *Server) Tweak(name string, ptr any, options *TweakOptions) {
+
+	value := reflect.ValueOf(ptr)
+	if value.Kind() != reflect.Pointer || value.IsNil() {
+		panic("tetraterm: tweak " + strconv.Quote(name) + " must be a non-nil pointer, not " + fmt.Sprintf("%T", ptr))
+	}
+
+	t := &tweak{
+		name:  name,
+		value: value.Elem(),
+	}
+
+	if options != nil {
+		t.options = *options
+	}
+
+	switch t.value.Kind() {
+
+	case reflect.Float32, reflect.Float64:
+		t.kind = tweakKindFloat
+		if t.options.Step == 0 {
+			t.options.Step = 0.1
+		}
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		t.kind = tweakKindInt
+		if len(t.options.Choices) > 0 {
+			t.kind = tweakKindEnum
+		}
+		if t.options.Step == 0 {
+			t.options.Step = 1
+		}
+
+	case reflect.Bool:
+		t.kind = tweakKindBool
+
+	case reflect.String:
+		t.kind = tweakKindString
+		if len(t.options.Choices) > 0 {
+			t.kind = tweakKindEnum
+		}
+
+	default:
+		panic("tetraterm: tweak " + strconv.Quote(name) + " points to an unsupported type, " + t.value.Type().String())
+
+	}
+
+	server.tweaks.mutex.Lock()
+	defer server.tweaks.mutex.Unlock()
+
+	for i, existing := range server.tweaks.tweaks {
+		if existing.name == name {
+			server.tweaks.tweaks[i] = t
+			return
+		}
+	}
+
+	server.tweaks.tweaks = append(server.tweaks.tweaks, t)
+
+}
+
+// initTweakPane creates the Tweaks tool pane, which allows editing the values registered through Server.Tweak().
+func (display *Display) initTweakPane() {
+
+	form := tview.NewForm()
+	form.SetBackgroundColor(tcell.ColorDefault)
+	form.SetFieldBackgroundColor(tcell.ColorDarkSlateGray)
+	form.SetFieldTextColor(tcell.ColorLightBlue)
+	form.SetLabelColor(tcell.ColorLightBlue)
+	form.SetItemPadding(0)
+
+	status := tview.NewTextView()
+	status.SetDynamicColors(true)
+	status.SetBackgroundColor(tcell.ColorDefault)
+	status.SetText("Enter: Apply, Up/Down: Step, Tab: Next Value")
+
+	pane := tview.NewFlex()
+	pane.SetDirection(tview.FlexRow)
+	pane.SetBorder(true)
+	pane.SetTitle("[ Tweaks ]")
+	pane.AddItem(form, 0, 1, true)
+	pane.AddItem(status, 1, 0, false)
+
+	display.addToolPane("tweaks", tcell.KeyF4, pane)
+
+	send := func(name, value string) {
+		res, err := display.sendRequest(newTweakSetPacket(name, value))
+		if err != nil {
+			status.SetText("[red]" + tview.Escape(err.Error()))
+		} else if errText := res.(*tweakSetPacket).Error; errText != "" {
+			status.SetText("[red]" + tview.Escape(name+": "+errText))
+		} else {
+			status.SetText("[green]Set " + tview.Escape(name) + " to " + tview.Escape(value))
+		}
+	}
+
+	layout := ""
+	tweaks := []tweakInfo{}
+	updating := false
+
+	rebuild := func() {
+
+		form.Clear(true)
+
+		for _, info := range tweaks {
+
+			info := info
+
+			switch info.Kind {
+
+			case tweakKindBool:
+				form.AddCheckbox(tview.Escape(info.Name)+": ", false, func(checked bool) {
+					if !updating {
+						send(info.Name, strconv.FormatBool(checked))
+					}
+				})
+
+			case tweakKindEnum:
+				form.AddDropDown(tview.Escape(info.Name)+": ", info.Options.Choices, -1, func(option string, optionIndex int) {
+					if !updating && optionIndex >= 0 {
+						send(info.Name, option)
+					}
+				})
+
+			default:
+				field := tview.NewInputField()
+				field.SetLabel(tview.Escape(info.Name) + ": ")
+				field.SetFieldWidth(16)
+
+				if info.Kind == tweakKindFloat {
+					field.SetAcceptanceFunc(tview.InputFieldFloat)
+				} else if info.Kind == tweakKindInt {
+					field.SetAcceptanceFunc(tview.InputFieldInteger)
+				}
+
+				field.SetDoneFunc(func(key tcell.Key) {
+					if key == tcell.KeyEnter {
+						send(info.Name, field.GetText())
+					}
+				})
+
+				if info.Kind == tweakKindFloat || info.Kind == tweakKindInt {
+					field.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+						if event.Key() == tcell.KeyUp || event.Key() == tcell.KeyDown {
+							value, err := strconv.ParseFloat(field.GetText(), 64)
+							if err != nil {
+								return nil
+							}
+							if event.Key() == tcell.KeyUp {
+								value += info.Options.Step
+							} else {
+								value -= info.Options.Step
+							}
+							if info.Options.Min != 0 || info.Options.Max != 0 {
+								value = clampFloat(value, info.Options.Min, info.Options.Max)
+							}
+							text := strconv.FormatFloat(value, 'f', -1, 64)
+							field.SetText(text)
+							send(info.Name, text)
+							return nil
+						}
+						return event
+					})
+				}
+
+				form.AddFormItem(field)
+			}
+
+		}
+
+	}
+
+	refresh := func() {
+
+		updating = true
+		defer func() { updating = false }()
+
+		for i, info := range tweaks {
+
+			item := form.GetFormItem(i)
+
+			label := tview.Escape(info.Name)
+			if (info.Kind == tweakKindFloat || info.Kind == tweakKindInt) && (info.Options.Min != 0 || info.Options.Max != 0) {
+				if value, err := strconv.ParseFloat(info.Value, 64); err == nil {
+					label += " " + slider(value, info.Options.Min, info.Options.Max, 10)
+				}
+			}
+			label += ": "
+
+			// We don't want to change a value while it's being edited.
+			if item.HasFocus() {
+				continue
+			}
+
+			switch field := item.(type) {
+			case *tview.Checkbox:
+				field.SetChecked(info.Value == "true")
+			case *tview.DropDown:
+				for c, choice := range info.Options.Choices {
+					if choice == info.Value {
+						field.SetCurrentOption(c)
+					}
+				}
+			case *tview.InputField:
+				field.SetLabel(label)
+				field.SetText(info.Value)
+			}
+
+		}
+
+	}
+
+	go func() {
+
+		for {
+
+			time.Sleep(time.Millisecond * 250)
+
+			if !display.running.Load() {
+				return
+			}
+
+			if !display.toolPaneVisible("tweaks") {
+				continue
+			}
+
+			resp, err := display.sendRequest(newTweaksPacket())
+			if err != nil {
+				continue
+			}
+
+			newTweaks := resp.(*tweaksPacket).Tweaks
+
+			display.App.QueueUpdate(func() {
+
+				newLayout := ""
+				for _, t := range newTweaks {
+					newLayout += t.Name + "\x00" + strconv.Itoa(t.Kind) + "\x00" + strings.Join(t.Options.Choices, "\x00") + "\n"
+				}
+
+				tweaks = newTweaks
+
+				if newLayout != layout {
+					layout = newLayout
+					rebuild()
+					if len(tweaks) == 0 {
+						status.SetText("No tweaks registered.")
+					}
+				}
+
+				refresh()
+
+			})
+
+		}
+
+	}()
+
+}
+
+func clampFloat(value, min, max float64) float64 {
+	if value < min {
+		return min
+	}
+	if value > max {
+		return max
+	}
+	return value
+}
+
+// slider returns a horizontal bar showing where the value lies between min and max.
+func slider(value, min, max float64, width int) string {
+
+	filled := 0
+	if max > min {
+		filled = int(clampFloat((value-min)/(max-min), 0, 1) * float64(width))
+	}
+
+	return strings.Repeat("■", filled) + strings.Repeat("□", width-filled)
+
+}
@@ -0,0 +1,356 @@
+package tetraterm
+
+import (
+	"errors"
+	"io/fs"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rivo/tview"
+	"github.com/solarlune/tetra3d"
+)
+
+// How often watched files are checked for changes.
+const watchPollInterval = time.Millisecond * 500
+
+// How long to wait after a change is detected for further changes (i.e. from saving several files at once)
+// before restarting the game.
+const watchSettleTime = time.Millisecond * 300
+
+// How long to wait for a restarted game to come back up before giving up on restoring the session's state.
+const sessionRestoreTimeout = time.Minute
+
+// WatchedExtensions are the extensions of the files that are watched for changes by Display.WatchGameSources().
+var WatchedExtensions = []string{
+	".go", ".mod", ".sum",
+	".gltf", ".glb", ".bin", ".dae",
+	".png", ".jpg", ".jpeg", ".bmp", ".gif",
+	".ogg", ".wav", ".mp3",
+	".ttf", ".otf", ".kage", ".json", ".txt", ".csv",
+}
+
+// WatchGameSources watches the source and asset files matched by the patterns given, restarting the game process
+// started with Display.StartGameProcess() when any of them change. Patterns are directories, which are watched
+// recursively if they end with "/..." (like Go package patterns, i.e. "./..."). Files are checked for changes by
+// polling their modification times, and only files with extensions in WatchedExtensions are watched. When the
+// game restarts, the selected node, expanded branches in the node tree, and the camera's transform are restored.
+// Like StartGameProcess(), this can be called before Display.Start(); files aren't checked until it's running.
+func (display *Display) WatchGameSources(patterns []string) error {
+
+	if len(patterns) == 0 {
+		return errors.New("no files given to watch")
+	}
+
+	for _, pattern := range patterns {
+		if _, err := watchedFiles(pattern); err != nil {
+			return err
+		}
+	}
+
+	go func() {
+
+		snapshot := watchSnapshot(patterns)
+
+		for {
+
+			time.Sleep(watchPollInterval)
+
+			// The Display hasn't started yet, or has stopped.
+			if !display.running.Load() {
+				continue
+			}
+
+			newSnapshot := watchSnapshot(patterns)
+			changed := changedFiles(snapshot, newSnapshot)
+
+			if len(changed) == 0 {
+				continue
+			}
+
+			// Wait for the files to settle, so saving several files at once only restarts the game once.
+			time.Sleep(watchSettleTime)
+			snapshot = watchSnapshot(patterns)
+
+			description := changed[0]
+			if len(changed) == 2 {
+				description += " (and 1 other file)"
+			} else if len(changed) > 2 {
+				description += " (and " + strconv.Itoa(len(changed)-1) + " other files)"
+			}
+
+			display.printProcessOutput("[skyblue]" + tview.Escape(description) + " changed; rebuilding...[-]")
+
+			display.restartGameProcess()
+
+		}
+
+	}()
+
+	return nil
+
+}
+
+// watchedFiles returns the modification times of the watched files matched by the pattern given.
+func watchedFiles(pattern string) (map[string]time.Time, error) {
+
+	files := map[string]time.Time{}
+
+	recursive := false
+	root := pattern
+
+	if pattern == "..." || strings.HasSuffix(pattern, "/...") {
+		recursive = true
+		root = strings.TrimSuffix(strings.TrimSuffix(pattern, "..."), "/")
+		if root == "" {
+			root = "."
+		}
+	}
+
+	err := filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+
+		if err != nil {
+			return err
+		}
+
+		if entry.IsDir() {
+			if path != root && (!recursive || strings.HasPrefix(entry.Name(), ".")) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		watched := false
+		for _, extension := range WatchedExtensions {
+			if strings.EqualFold(filepath.Ext(path), extension) {
+				watched = true
+				break
+			}
+		}
+
+		if !watched {
+			return nil
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			// The file may have been deleted since the directory was read.
+			return nil
+		}
+
+		files[path] = info.ModTime()
+
+		return nil
+
+	})
+
+	return files, err
+
+}
+
+// watchSnapshot returns the modification times of all of the watched files matched by the patterns given.
+func watchSnapshot(patterns []string) map[string]time.Time {
+
+	snapshot := map[string]time.Time{}
+
+	for _, pattern := range patterns {
+		// Errors (i.e. from a directory being removed) are ignored, and the next poll tries again.
+		files, _ := watchedFiles(pattern)
+		for path, modTime := range files {
+			snapshot[path] = modTime
+		}
+	}
+
+	return snapshot
+
+}
+
+// changedFiles returns the files that were added, removed or modified between the snapshots given.
+func changedFiles(old, new map[string]time.Time) []string {
+
+	changed := []string{}
+
+	for path, modTime := range new {
+		if oldModTime, exists := old[path]; !exists || !oldModTime.Equal(modTime) {
+			changed = append(changed, path)
+		}
+	}
+
+	for path := range old {
+		if _, exists := new[path]; !exists {
+			changed = append(changed, path)
+		}
+	}
+
+	return changed
+
+}
+
+// sessionState is the state of the Display that's restored after the game is restarted. Nodes are identified
+// by their paths, as their IDs change between sessions.
+type sessionState struct {
+	selectedPath    string
+	expanded        map[string]bool
+	hasCamera       bool
+	cameraTransform tetra3d.Matrix4
+}
+
+// captureSessionState records the selected node, the expanded branches of the node tree, and the camera's
+// transform. This can't be called from the UI goroutine.
+func (display *Display) captureSessionState() sessionState {
+
+	state := sessionState{expanded: map[string]bool{}}
+
+	done := make(chan struct{})
+
+	display.App.QueueUpdate(func() {
+
+		defer close(done)
+
+		paths := sceneTreePaths(display.currentSceneTree)
+
+		for id, path := range paths {
+			if treeNode, exists := display.SceneNodesToTreeNodes[id]; exists && len(treeNode.GetChildren()) > 0 {
+				state.expanded[path] = treeNode.IsExpanded()
+			}
+		}
+
+		if current := display.TreeView.GetCurrentNode(); current != nil {
+			if node, ok := current.GetReference().(sceneNode); ok {
+				state.selectedPath = paths[node.NodeID]
+			}
+		}
+
+	})
+
+	<-done
+
+	if res, err := display.sendRequest(newCameraStatePacket()); err == nil {
+		camera := res.(*cameraStatePacket)
+		state.hasCamera = camera.HasCamera
+		state.cameraTransform = camera.Transform
+	}
+
+	return state
+
+}
+
+// restoreSessionState waits for the restarted game to come back up, and then restores the state given.
+// This can't be called from the UI goroutine.
+func (display *Display) restoreSessionState(state sessionState) {
+
+	start := time.Now()
+
+	var tree sceneNode
+
+	// Wait for the new game's server to respond with a scene.
+	for {
+
+		if time.Since(start) > sessionRestoreTimeout || !display.running.Load() {
+			return
+		}
+
+		time.Sleep(time.Millisecond * 250)
+
+		res, err := display.sendRequest(newSceneRefreshPacket())
+		if err == nil && res.(*sceneRefreshPacket).SceneTree.Name != "" {
+			tree = res.(*sceneRefreshPacket).SceneTree
+			break
+		}
+
+	}
+
+	paths := sceneTreePaths(tree)
+
+	selectedID := uint32(0)
+	hasSelected := false
+
+	for id, path := range paths {
+		if path == state.selectedPath {
+			selectedID = id
+			hasSelected = true
+			break
+		}
+	}
+
+	if hasSelected {
+		display.sendRequest(newNodeSelectPacket(selectedID))
+	}
+
+	if state.hasCamera {
+		// The camera is set by the game's first call to Server.Draw(), so it may take a moment to be available.
+		for time.Since(start) < sessionRestoreTimeout {
+			packet := newCameraStatePacket()
+			packet.Set = true
+			packet.Transform = state.cameraTransform
+			res, err := display.sendRequest(packet)
+			if err == nil && res.(*cameraStatePacket).HasCamera {
+				break
+			}
+			time.Sleep(time.Millisecond * 250)
+		}
+	}
+
+	// Wait for the node tree to be rebuilt from the new scene before restoring it.
+	for time.Since(start) < sessionRestoreTimeout {
+
+		// QueueUpdate() blocks until the update's run, so the result's buffered rather than waiting to be received.
+		restored := make(chan bool, 1)
+
+		display.App.QueueUpdate(func() {
+
+			for id := range paths {
+				if _, exists := display.SceneNodesToTreeNodes[id]; !exists {
+					restored <- false
+					return
+				}
+			}
+
+			for id, path := range sceneTreePaths(display.currentSceneTree) {
+				if expanded, exists := state.expanded[path]; exists {
+					if treeNode, exists := display.SceneNodesToTreeNodes[id]; exists {
+						treeNode.SetExpanded(expanded)
+					}
+				}
+			}
+
+			if hasSelected {
+				display.showTreeNode(selectedID, sceneTreeAncestors(display.currentSceneTree, selectedID))
+			}
+
+			restored <- true
+
+		})
+
+		if <-restored {
+			display.printProcessOutput("[skyblue]Restored the previous session's selection and camera.[-]")
+			return
+		}
+
+		time.Sleep(time.Millisecond * 250)
+
+	}
+
+}
+
+// sceneTreePaths returns the paths (i.e. /Root/Player) of each node in the tree, by their IDs.
+func sceneTreePaths(tree sceneNode) map[uint32]string {
+
+	paths := map[uint32]string{}
+
+	var loop func(node sceneNode, parentPath string)
+
+	loop = func(node sceneNode, parentPath string) {
+		path := parentPath + "/" + node.Name
+		paths[node.NodeID] = path
+		for _, child := range node.Children {
+			loop(child, path)
+		}
+	}
+
+	loop(tree, "")
+
+	return paths
+
+}
@@ -0,0 +1,155 @@
+package tetraterm
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+// How long after the terminal last asked for watch values that the Server keeps evaluating them.
+const watchRequestTimeout = time.Second
+
+type watch struct {
+	name string
+	fn   func() any
+}
+
+// watchList holds the watches registered through Server.Watch(), along with their most recently
+// evaluated values.
+type watchList struct {
+	mutex     sync.Mutex
+	watches   []watch
+	values    []valueNode
+	requested time.Time
+}
+
+// update evaluates each watch and serializes its value, but only if the terminal has asked for
+// the watches recently; this is called on the game thread from Server.Update().
+func (watches *watchList) update() {
+
+	watches.mutex.Lock()
+
+	if time.Since(watches.requested) > watchRequestTimeout {
+		watches.mutex.Unlock()
+		return
+	}
+
+	// The watches are evaluated without the lock held, so watch functions can call Server.Watch() and the like.
+	list := append([]watch{}, watches.watches...)
+
+	watches.mutex.Unlock()
+
+	values := make([]valueNode, 0, len(list))
+
+	for _, w := range list {
+		values = append(values, evaluateWatch(w))
+	}
+
+	watches.mutex.Lock()
+	watches.values = values
+	watches.mutex.Unlock()
+
+}
+
+func evaluateWatch(w watch) (value valueNode) {
+
+	defer func() {
+		if r := recover(); r != nil {
+			value = valueNode{Name: w.name, Value: fmt.Sprintf("panic: %v", r)}
+		}
+	}()
+
+	return newValueTree(w.name, w.fn())
+
+}
+
+// latest returns the most recently evaluated watch values, and marks the watches as having been requested.
+func (watches *watchList) latest() []valueNode {
+
+	watches.mutex.Lock()
+	defer watches.mutex.Unlock()
+
+	watches.requested = time.Now()
+
+	return append([]valueNode{}, watches.values...)
+
+}
+
+// Watch registers a named watch, which displays the value returned by the function given in the terminal's
+// Watches pane. Structs, slices and maps are displayed as expandable trees. The function is called from
+// Server.Update() on the game thread, and only while the Watches pane is open in the terminal. Registering
+// a watch with the name of an existing watch replaces it.
+//
+// Example usage:
+//
+//	server.Watch("player", func() any { return game.Player })
+func (server *Server) Watch(name string, fn func() any) {
+
+	server.watches.mutex.Lock()
+	defer server.watches.mutex.Unlock()
+
+	for i, w := range server.watches.watches {
+		if w.name == name {
+			server.watches.watches[i].fn = fn
+			return
+		}
+	}
+
+	server.watches.watches = append(server.watches.watches, watch{name: name, fn: fn})
+
+}
+
+// Unwatch removes the named watch.
+func (server *Server) Unwatch(name string) {
+
+	server.watches.mutex.Lock()
+	defer server.watches.mutex.Unlock()
+
+	for i, w := range server.watches.watches {
+		if w.name == name {
+			server.watches.watches = append(server.watches.watches[:i], server.watches.watches[i+1:]...)
+			return
+		}
+	}
+
+}
+
+// initWatchPane creates the Watches tool pane, which displays the values of the watches registered
+// through Server.Watch().
+func (display *Display) initWatchPane() {
+
+	pane := newValueTreeView()
+	pane.SetBorder(true)
+	pane.SetTitle("[ Watches ]")
+
+	display.addToolPane("watches", tcell.KeyF3, pane)
+
+	go func() {
+
+		for {
+
+			time.Sleep(time.Millisecond * 250)
+
+			if !display.running.Load() {
+				return
+			}
+
+			if !display.toolPaneVisible("watches") {
+				continue
+			}
+
+			resp, err := display.sendRequest(newWatchesPacket())
+			if err == nil {
+				values := resp.(*watchesPacket).Watches
+				display.App.QueueUpdate(func() {
+					pane.SetValues(values)
+				})
+			}
+
+		}
+
+	}()
+
+}
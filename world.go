@@ -0,0 +1,381 @@
+package tetraterm
+
+import (
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+	"github.com/solarlune/tetra3d"
+)
+
+// worldSettings are the editable settings of a scene's World.
+type worldSettings struct {
+	Name            string
+	ClearColor      tetra3d.Color
+	FogOn           bool
+	FogMode         tetra3d.FogMode
+	FogColor        tetra3d.Color
+	FogStart        float32
+	FogEnd          float32
+	FogCurve        int
+	DitheredFogSize float32
+	LightingOn      bool
+	HasAmbientLight bool
+	AmbientOn       bool
+	AmbientColor    tetra3d.Color
+	AmbientEnergy   float32
+}
+
+// worldFogModes are the fog modes that can be chosen from the World pane, in the order of their values.
+var worldFogModes = []string{"Add", "Subtract", "Overwrite", "Transparent"}
+
+// worldFogCurves are the fog curves that can be chosen from the World pane, in the order of their values.
+var worldFogCurves = []string{"Linear", "Out Circ", "In Circ"}
+
+// readWorldSettings returns the editable settings of the World given.
+func readWorldSettings(world *tetra3d.World) worldSettings {
+
+	settings := worldSettings{
+		Name:            world.Name,
+		ClearColor:      world.ClearColor,
+		FogOn:           world.FogOn,
+		FogMode:         world.FogMode,
+		FogColor:        world.FogColor,
+		FogCurve:        world.FogCurve,
+		DitheredFogSize: world.DitheredFogSize,
+		LightingOn:      world.LightingOn,
+	}
+
+	if len(world.FogRange) >= 2 {
+		settings.FogStart = world.FogRange[0]
+		settings.FogEnd = world.FogRange[1]
+	}
+
+	if world.AmbientLight != nil {
+		settings.HasAmbientLight = true
+		settings.AmbientOn = world.AmbientLight.IsOn()
+		settings.AmbientColor = world.AmbientLight.Color()
+		settings.AmbientEnergy = world.AmbientLight.Energy()
+	}
+
+	return settings
+
+}
+
+// apply sets the named field of the settings on the World given. Only the one field is set, so changes the game
+// has made to the rest of the World since the settings were read aren't overwritten.
+func (settings worldSettings) apply(world *tetra3d.World, field string) error {
+
+	switch field {
+	case "ClearColor":
+		world.ClearColor = settings.ClearColor
+	case "FogOn":
+		world.FogOn = settings.FogOn
+	case "FogMode":
+		world.FogMode = settings.FogMode
+	case "FogColor":
+		world.FogColor = settings.FogColor
+	case "FogStart", "FogEnd":
+		// The other end of the range is kept as the game has it.
+		if len(world.FogRange) < 2 {
+			world.FogRange = []float32{settings.FogStart, settings.FogEnd}
+		} else if field == "FogStart" {
+			world.FogRange[0] = settings.FogStart
+		} else {
+			world.FogRange[1] = settings.FogEnd
+		}
+	case "FogCurve":
+		world.FogCurve = settings.FogCurve
+	case "DitheredFogSize":
+		world.DitheredFogSize = settings.DitheredFogSize
+	case "LightingOn":
+		world.LightingOn = settings.LightingOn
+	case "AmbientOn", "AmbientColor", "AmbientEnergy":
+		if world.AmbientLight == nil {
+			return errors.New("the World has no ambient light")
+		}
+		switch field {
+		case "AmbientOn":
+			world.AmbientLight.SetOn(settings.AmbientOn)
+		case "AmbientColor":
+			world.AmbientLight.SetColor(settings.AmbientColor)
+		case "AmbientEnergy":
+			world.AmbientLight.SetEnergy(settings.AmbientEnergy)
+		}
+	default:
+		return errors.New("unknown World setting " + strconv.Quote(field))
+	}
+
+	return nil
+
+}
+
+// handleWorld applies the setting named by the packet's Set field to the active scene's World, if there is one,
+// and fills the packet out with the World's settings. This should be run on the game thread.
+func (server *Server) handleWorld(packet *worldPacket) error {
+
+	if server.activeScene == nil || server.activeScene.World == nil {
+		return errors.New("the active scene has no World")
+	}
+
+	if packet.Set != "" {
+		if err := packet.Settings.apply(server.activeScene.World, packet.Set); err != nil {
+			return err
+		}
+	}
+
+	packet.Settings = readWorldSettings(server.activeScene.World)
+
+	return nil
+
+}
+
+// parseColorText parses a color typed into the terminal, either as a hex code (#ff8000) or as red, green, and
+// blue values from 0 to 1 separated by spaces or commas (1 0.5 0). The alpha of the color given is kept.
+func parseColorText(text string, alpha float32) (tetra3d.Color, error) {
+
+	text = strings.TrimSpace(text)
+
+	if strings.HasPrefix(text, "#") {
+
+		bytes, err := hex.DecodeString(text[1:])
+		if err != nil || len(bytes) != 3 {
+			return tetra3d.Color{}, errors.New("hex colors must have six digits (i.e. #ff8000)")
+		}
+
+		return tetra3d.NewColor(float32(bytes[0])/255, float32(bytes[1])/255, float32(bytes[2])/255, alpha), nil
+
+	}
+
+	fields := strings.FieldsFunc(text, func(r rune) bool { return r == ' ' || r == ',' })
+
+	if len(fields) != 3 {
+		return tetra3d.Color{}, errors.New("colors must be a hex code (#ff8000) or three values (1 0.5 0)")
+	}
+
+	values := [3]float32{}
+
+	for i, field := range fields {
+		value, err := strconv.ParseFloat(field, 32)
+		if err != nil {
+			return tetra3d.Color{}, errors.New("couldn't read color value " + strconv.Quote(field))
+		}
+		values[i] = float32(value)
+	}
+
+	return tetra3d.NewColor(values[0], values[1], values[2], alpha), nil
+
+}
+
+// formatColorText formats the color given in the form parseColorText() reads.
+func formatColorText(color tetra3d.Color) string {
+	format := func(value float32) string {
+		return strconv.FormatFloat(float64(value), 'f', -1, 32)
+	}
+	return format(color.R) + " " + format(color.G) + " " + format(color.B)
+}
+
+// initWorldPane creates the World tool pane, which edits the fog, lighting, and clear color settings of the
+// active scene's World. The settings are fetched from the game each time the pane's shown.
+func (display *Display) initWorldPane() {
+
+	form := tview.NewForm()
+	form.SetBackgroundColor(tcell.ColorDefault)
+	form.SetFieldBackgroundColor(tcell.ColorDarkSlateGray)
+	form.SetFieldTextColor(tcell.ColorLightBlue)
+	form.SetLabelColor(tcell.ColorLightBlue)
+	form.SetItemPadding(0)
+
+	status := tview.NewTextView()
+	status.SetDynamicColors(true)
+	status.SetBackgroundColor(tcell.ColorDefault)
+
+	pane := tview.NewFlex()
+	pane.SetDirection(tview.FlexRow)
+	pane.SetBorder(true)
+	pane.SetTitle("[ World ]")
+	pane.AddItem(form, 0, 1, true)
+	pane.AddItem(status, 1, 0, false)
+
+	display.addToolPane("world", tcell.KeyF14, pane)
+
+	const help = "[gray]Fields apply on Enter; colors are #rrggbb or R G B (0-1). Ctrl+L: Reload[-]"
+
+	var build func(settings worldSettings)
+
+	load := func() {
+		go func() {
+
+			res, err := display.sendRequest(newWorldPacket())
+
+			display.App.QueueUpdate(func() {
+				if err != nil {
+					form.Clear(true)
+					status.SetText("[red]Couldn't fetch the World: " + tview.Escape(err.Error()) + "[-]")
+				} else if errText := res.(*worldPacket).Error; errText != "" {
+					form.Clear(true)
+					status.SetText("[red]" + tview.Escape(errText) + "[-]")
+				} else {
+					build(res.(*worldPacket).Settings)
+				}
+			})
+
+		}()
+	}
+
+	build = func(settings worldSettings) {
+
+		form.Clear(true)
+
+		pane.SetTitle("[ World: " + tview.Escape(settings.Name) + " ]")
+		status.SetText(help)
+
+		// Dropdowns call their selected functions when they're created, so changes aren't sent until the form's built.
+		building := true
+
+		// apply sends the named field to the game; only that field is set, so other settings the game's changed
+		// since the pane was loaded are kept.
+		apply := func(field string) {
+
+			if building {
+				return
+			}
+
+			packet := newWorldPacket()
+			packet.Set = field
+			packet.Settings = settings
+
+			go func() {
+				res, err := display.sendRequest(packet)
+				display.App.QueueUpdate(func() {
+					if err != nil {
+						status.SetText("[red]Couldn't apply the settings: " + tview.Escape(err.Error()) + "[-]")
+					} else if errText := res.(*worldPacket).Error; errText != "" {
+						status.SetText("[red]" + tview.Escape(errText) + "[-]")
+					} else {
+						status.SetText("[green]Applied.[-] " + help)
+					}
+				})
+			}()
+
+		}
+
+		addCheckbox := func(label, field string, target *bool) {
+			form.AddCheckbox(label, *target, func(checked bool) {
+				*target = checked
+				apply(field)
+			})
+		}
+
+		addDropDown := func(label, field string, options []string, target *int) {
+			form.AddDropDown(label, options, *target, func(option string, index int) {
+				if index >= 0 {
+					*target = index
+					apply(field)
+				}
+			})
+		}
+
+		// Text fields apply when Enter's pressed, so values aren't sent while they're partially typed.
+		addField := func(label, field, text string, accept func(text string) error) {
+			input := tview.NewInputField()
+			input.SetLabel(label)
+			input.SetText(text)
+			input.SetFieldWidth(20)
+			input.SetDoneFunc(func(key tcell.Key) {
+				if key != tcell.KeyEnter {
+					return
+				}
+				if err := accept(input.GetText()); err != nil {
+					status.SetText("[red]" + tview.Escape(err.Error()) + "[-]")
+					return
+				}
+				apply(field)
+			})
+			form.AddFormItem(input)
+		}
+
+		addColor := func(label, field string, target *tetra3d.Color) {
+			addField(label, field, formatColorText(*target), func(text string) error {
+				color, err := parseColorText(text, target.A)
+				if err != nil {
+					return err
+				}
+				*target = color
+				return nil
+			})
+		}
+
+		addNumber := func(label, field string, target *float32) {
+			addField(label, field, strconv.FormatFloat(float64(*target), 'f', -1, 32), func(text string) error {
+				value, err := strconv.ParseFloat(text, 32)
+				if err != nil {
+					return errors.New("couldn't read number " + strconv.Quote(text))
+				}
+				*target = float32(value)
+				return nil
+			})
+		}
+
+		addColor("Clear Color: ", "ClearColor", &settings.ClearColor)
+		addCheckbox("Lighting On: ", "LightingOn", &settings.LightingOn)
+		addCheckbox("Fog On: ", "FogOn", &settings.FogOn)
+		form.AddDropDown("Fog Mode: ", worldFogModes, int(settings.FogMode), func(option string, index int) {
+			if index >= 0 {
+				settings.FogMode = tetra3d.FogMode(index)
+				apply("FogMode")
+			}
+		})
+		addColor("Fog Color: ", "FogColor", &settings.FogColor)
+		addNumber("Fog Start (0-1): ", "FogStart", &settings.FogStart)
+		addNumber("Fog End (0-1): ", "FogEnd", &settings.FogEnd)
+		addDropDown("Fog Curve: ", "FogCurve", worldFogCurves, &settings.FogCurve)
+		addNumber("Dithered Fog Size: ", "DitheredFogSize", &settings.DitheredFogSize)
+
+		if settings.HasAmbientLight {
+			addCheckbox("Ambient Light On: ", "AmbientOn", &settings.AmbientOn)
+			addColor("Ambient Color: ", "AmbientColor", &settings.AmbientColor)
+			addNumber("Ambient Energy: ", "AmbientEnergy", &settings.AmbientEnergy)
+		}
+
+		building = false
+
+	}
+
+	pane.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		if event.Key() == tcell.KeyCtrlL {
+			load()
+			return nil
+		}
+		return event
+	})
+
+	// The settings are fetched when the pane's shown, rather than polled, so they don't change while being edited.
+	go func() {
+
+		visible := false
+
+		for {
+
+			time.Sleep(time.Millisecond * 250)
+
+			if !display.running.Load() {
+				return
+			}
+
+			if nowVisible := display.toolPaneVisible("world"); nowVisible != visible {
+				visible = nowVisible
+				if visible {
+					load()
+				}
+			}
+
+		}
+
+	}()
+
+}